@@ -0,0 +1,130 @@
+// Tideland Go JSON Web Token - Algorithm Registry
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"crypto"
+	"fmt"
+	"sync"
+)
+
+// SigningMethod implements the signing and verification for an
+// algorithm. Keys not matching the method have to be rejected with
+// a KeyTypeError.
+type SigningMethod interface {
+	// Sign creates the signature for the data.
+	Sign(data []byte, key Key) (Signature, error)
+
+	// Verify checks if the signature is correct for the data.
+	Verify(data []byte, sig Signature, key Key) error
+
+	// KeyType returns the JWK key type of the keys used for the
+	// selection out of key sets. An empty type accepts all keys.
+	KeyType() string
+}
+
+// registry contains the signing methods of all algorithms.
+var registry = struct {
+	mu       sync.RWMutex
+	methods  map[Algorithm]SigningMethod
+	disabled map[Algorithm]bool
+}{
+	methods: map[Algorithm]SigningMethod{
+		ES256: builtinMethod{ES256, crypto.SHA256},
+		ES384: builtinMethod{ES384, crypto.SHA384},
+		ES512: builtinMethod{ES512, crypto.SHA512},
+		EdDSA: builtinMethod{EdDSA, 0},
+		HS256: builtinMethod{HS256, crypto.SHA256},
+		HS384: builtinMethod{HS384, crypto.SHA384},
+		HS512: builtinMethod{HS512, crypto.SHA512},
+		PS256: builtinMethod{PS256, crypto.SHA256},
+		PS384: builtinMethod{PS384, crypto.SHA384},
+		PS512: builtinMethod{PS512, crypto.SHA512},
+		RS256: builtinMethod{RS256, crypto.SHA256},
+		RS384: builtinMethod{RS384, crypto.SHA384},
+		RS512: builtinMethod{RS512, crypto.SHA512},
+		NONE:  builtinMethod{NONE, 0},
+	},
+	disabled: map[Algorithm]bool{},
+}
+
+// RegisterAlgorithm registers the signing method for a new algorithm.
+// Already registered algorithms cannot be replaced.
+func RegisterAlgorithm(algorithm Algorithm, method SigningMethod) error {
+	if algorithm == "" || method == nil {
+		return fmt.Errorf("cannot register algorithm: missing name or signing method")
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.methods[algorithm]; ok {
+		return fmt.Errorf("cannot register algorithm: '%s' is already registered", algorithm)
+	}
+	registry.methods[algorithm] = method
+	return nil
+}
+
+// DisableAlgorithm disables an algorithm process-wide. Signing and
+// verification with it fail with ErrAlgorithmNotPermitted.
+func DisableAlgorithm(algorithm Algorithm) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.disabled[algorithm] = true
+}
+
+// EnableAlgorithm enables a formerly disabled algorithm again.
+func EnableAlgorithm(algorithm Algorithm) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.disabled, algorithm)
+}
+
+// lookupMethod returns the signing method of the algorithm.
+func lookupMethod(algorithm Algorithm) (SigningMethod, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	method, ok := registry.methods[algorithm]
+	if !ok {
+		return nil, &AlgorithmError{Algorithm: algorithm, Err: ErrUnsupportedAlgorithm}
+	}
+	if registry.disabled[algorithm] {
+		return nil, &AlgorithmError{Algorithm: algorithm, Err: ErrAlgorithmNotPermitted}
+	}
+	return method, nil
+}
+
+// builtinMethod implements the algorithms of this package.
+type builtinMethod struct {
+	algorithm Algorithm
+	hash      crypto.Hash
+}
+
+// Sign implements SigningMethod.
+func (m builtinMethod) Sign(data []byte, key Key) (Signature, error) {
+	return m.algorithm.sign(data, key, m.hash)
+}
+
+// Verify implements SigningMethod.
+func (m builtinMethod) Verify(data []byte, sig Signature, key Key) error {
+	return m.algorithm.verify(data, sig, key, m.hash)
+}
+
+// KeyType implements SigningMethod.
+func (m builtinMethod) KeyType() string {
+	switch m.algorithm {
+	case ES256, ES384, ES512:
+		return KeyTypeEC
+	case EdDSA:
+		return KeyTypeOKP
+	case HS256, HS384, HS512:
+		return KeyTypeOct
+	case PS256, PS384, PS512, RS256, RS384, RS512:
+		return KeyTypeRSA
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,109 @@
+// Tideland Go JSON Web Token - Algorithm Registry - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestAlgorithmRegister verifies the registration of a custom
+// algorithm.
+func TestAlgorithmRegister(t *testing.T) {
+	const XS256 jwt.Algorithm = "XS256"
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	key := kmsKey("key-1")
+	// Not yet registered.
+	_, err := XS256.Sign(data, key)
+	verify.IsError(t, err, jwt.ErrUnsupportedAlgorithm)
+	err = jwt.RegisterAlgorithm(XS256, kmsMethod{})
+	verify.NoError(t, err)
+	err = jwt.RegisterAlgorithm(XS256, kmsMethod{})
+	verify.ErrorMatch(t, err, ".*'XS256' is already registered.*")
+	err = jwt.RegisterAlgorithm(jwt.HS256, kmsMethod{})
+	verify.ErrorMatch(t, err, ".*'HS256' is already registered.*")
+	// Signing and verification.
+	signature, err := XS256.Sign(data, key)
+	verify.NoError(t, err)
+	err = XS256.Verify(data, signature, key)
+	verify.NoError(t, err)
+	_, err = XS256.Sign(data, []byte("secret"))
+	verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	// Tokens.
+	tokenEnc, err := jwt.Encode(initClaims(), key, XS256)
+	verify.NoError(t, err)
+	tokenVer, err := jwt.Verify(tokenEnc.String(), key)
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.Algorithm(), XS256)
+	_, err = jwt.Verify(tokenEnc.String(), kmsKey("key-2"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	_, err = jwt.Verify(tokenEnc.String(), []byte("secret"))
+	verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+}
+
+// TestAlgorithmDisable verifies the disabling of algorithms.
+func TestAlgorithmDisable(t *testing.T) {
+	key := []byte("secret")
+	tokenEnc, err := jwt.Encode(initClaims(), key, jwt.HS384)
+	verify.NoError(t, err)
+	jwt.DisableAlgorithm(jwt.HS384)
+	t.Cleanup(func() { jwt.EnableAlgorithm(jwt.HS384) })
+	_, err = jwt.Verify(tokenEnc.String(), key)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = jwt.Encode(initClaims(), key, jwt.HS384)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	// Other algorithms are not affected.
+	_, err = jwt.Encode(initClaims(), key, jwt.HS256)
+	verify.NoError(t, err)
+	jwt.EnableAlgorithm(jwt.HS384)
+	_, err = jwt.Verify(tokenEnc.String(), key)
+	verify.NoError(t, err)
+	// Unknown algorithms.
+	_, err = jwt.Verify("eyJhbGciOiJYWTUxMiJ9.e30.", key)
+	verify.IsError(t, err, jwt.ErrUnsupportedAlgorithm)
+}
+
+// kmsKey simulates a handle of a key inside a key management service.
+type kmsKey string
+
+// kmsMethod simulates the signing using a key management service.
+type kmsMethod struct{}
+
+// Sign implements jwt.SigningMethod.
+func (m kmsMethod) Sign(data []byte, key jwt.Key) (jwt.Signature, error) {
+	k, ok := key.(kmsKey)
+	if !ok {
+		return nil, &jwt.KeyTypeError{Algorithm: "XS256", KeyType: fmt.Sprintf("%T", key)}
+	}
+	mac := hmac.New(sha256.New, []byte(k))
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Verify implements jwt.SigningMethod.
+func (m kmsMethod) Verify(data []byte, sig jwt.Signature, key jwt.Key) error {
+	expected, err := m.Sign(data, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, expected) {
+		return jwt.ErrInvalidSignature
+	}
+	return nil
+}
+
+// KeyType implements jwt.SigningMethod.
+func (m kmsMethod) KeyType() string {
+	return ""
+}
@@ -8,34 +8,73 @@
 package jwt
 
 import (
+	"bytes"
+	"container/list"
 	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"hash/maphash"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dolthub/swiss"
 )
 
-// cacheEntry manages a token and its access time.
+// cacheEntry manages a token and its access time. The thumbprint
+// identifies the key the token has been verified with, it is nil if
+// the token has only been decoded. Tokens loaded from the store only
+// have the digest of the token and the thumbprint. Legacy marks tokens
+// with ASN.1 DER encoded ECDSA signatures.
 type cacheEntry struct {
-	token    *JWT
-	accessed time.Time
+	token      *JWT
+	thumbprint []byte
+	digest     []byte
+	legacy     bool
+	accessed   time.Time
 }
 
-// defaultTimeout is the default timeout for synchronous actions.
-const defaultTimeout = 5 * time.Second
+// cacheShards is the maximum number of independently locked
+// parts of the cache.
+const cacheShards = 32
+
+// cacheShard is one part of the cache with its own lock. Its
+// entries are the elements of the LRU list of the cache.
+type cacheShard struct {
+	mu      sync.Mutex
+	entries *swiss.Map[string, *list.Element]
+}
 
 // Cache provides a caching for tokens so that these
 // don't have to be decoded or verified multiple times.
+// It can be used concurrently, the entries are spread
+// over shards with their own locks. One list keeps all
+// entries in the order of their last access, so that the
+// least recently used ones can be evicted. Its lock is
+// never held while taking the one of a shard.
 type Cache struct {
 	ctx        context.Context
-	entries    *swiss.Map[string, *cacheEntry]
+	seed       maphash.Seed
+	shards     []cacheShard
+	lruMu      sync.Mutex
+	lru        *list.List
+	count      atomic.Int64
+	bytes      atomic.Int64
+	evictMu    sync.Mutex
+	maxEntries int
+	maxBytes   int
+	stats      cacheStats
+	store      CacheStore
+	secret     []byte
 	ttl        time.Duration
 	leeway     time.Duration
 	interval   time.Duration
-	maxEntries int
-	actionc    chan func()
+	clock      Clock
 }
 
 // NewCache creates a new JWT caching. The ttl value controls
@@ -43,42 +82,95 @@ type Cache struct {
 // leeway is used for the time validation of the token itself.
 // The duration of the interval controls how often the background
 // cleanup is running. Final configuration parameter is the maximum
-// number of entries inside the cache. If these grow too fast the
-// ttl will be temporarily reduced for cleanup.
-func NewCache(ctx context.Context, ttl, leeway, interval time.Duration, maxEntries int) *Cache {
+// number of entries inside the cache. Additionally the total length
+// of the cached tokens can be limited with WithMaxBytes. If a limit
+// is reached the least recently used tokens are evicted. The clock
+// used for the validation and the ttl can be set with WithClock,
+// an observer of the cache events with WithCacheObserver. A store
+// sharing the verified tokens with other caches can be set with
+// WithCacheStore. Failures of the store, also a missing secret, are
+// only reported to the statistics and the observer, the cache then
+// works locally. When
+// the context is done the cache is emptied and all further calls
+// return an error.
+func NewCache(ctx context.Context, ttl, leeway, interval time.Duration, maxEntries int, options ...Option) *Cache {
+	o := newOptions(options)
 	c := &Cache{
 		ctx:        ctx,
-		entries:    swiss.NewMap[string, *cacheEntry](42),
+		seed:       maphash.MakeSeed(),
+		shards:     make([]cacheShard, max(1, min(cacheShards, maxEntries))),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   o.maxBytes,
 		ttl:        ttl,
 		leeway:     leeway,
 		interval:   interval,
-		maxEntries: maxEntries,
-		actionc:    make(chan func(), 1),
+		clock:      o.clock,
+	}
+	c.stats.observer = o.cacheObserver
+	switch {
+	case o.cacheStore == nil:
+	case len(o.cacheSecret) == 0:
+		c.stats.storeFailed(fmt.Errorf("cannot use the store: %w: missing secret", ErrInvalidKey))
+	default:
+		c.store = o.cacheStore
+		c.secret = o.cacheSecret
+	}
+	for i := range c.shards {
+		c.shards[i].entries = swiss.NewMap[string, *list.Element](42)
 	}
 	go c.backend()
 	return c
 }
 
-// Get tries to retrieve a token from the cache.
+// Get tries to retrieve a token from the cache. It is returned
+// regardless if it has been verified or only decoded.
 func (c *Cache) Get(st string) (*JWT, error) {
-	var token *JWT
-	aerr := c.doSync(func() {
-		if c.entries == nil {
-			return
-		}
-		entry, ok := c.entries.Get(st)
-		if !ok {
-			return
-		}
-		if !entry.token.IsValid(c.leeway) {
-			// Remove invalid token.
-			c.entries.Delete(st)
+	entry, err := c.get(st)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		c.stats.miss()
+		return nil, nil
+	}
+	c.stats.hit()
+	return entry.token, nil
+}
+
+// Verify tries to retrieve a token from the cache which has been
+// verified with an equivalent key, e.g. the public key of the private
+// key used for encoding. The options are checked for it like during
+// the verification. Otherwise the token is verified and put. Tokens
+// only decoded are never returned, tokens with legacy ECDSA signatures
+// only with WithLegacyECDSA. The key of the returned token is the
+// verification key.
+func (c *Cache) Verify(st string, key Key, options ...Option) (*JWT, error) {
+	entry, err := c.get(st)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(options)
+	if entry != nil && (!entry.legacy || o.legacyECDSA) {
+		if verifyKey, ok := c.isVerifiedWith(entry, key); ok {
+			if err = checkCached(entry.token, o); err != nil {
+				return nil, err
+			}
+			c.stats.hit()
+			// Return the verification key like Verify, not the
+			// one the token has been put with.
+			verified := *entry.token
+			verified.key = verifyKey
+			return &verified, nil
 		}
-		entry.accessed = time.Now()
-		token = entry.token
-	}, defaultTimeout)
-	if aerr != nil {
-		return nil, aerr
+	}
+	c.stats.miss()
+	token, err := Verify(st, key, options...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.Put(token); err != nil {
+		return nil, err
 	}
 	return token, nil
 }
@@ -87,142 +179,394 @@ func (c *Cache) Get(st string) (*JWT, error) {
 // the requests authorization header. Otherwise it decodes it and
 // puts it.
 func (c *Cache) RequestDecode(req *http.Request) (*JWT, error) {
-	var token *JWT
-	var err error
-	aerr := c.doSync(func() {
-		var st string
-		if st, err = c.requestToken(req); err != nil {
-			return
-		}
-		if token, err = c.Get(st); err != nil {
-			return
-		}
-		if token, err = Decode(st); err != nil {
-			return
-		}
-		_, err = c.Put(token)
-	}, defaultTimeout)
-	if aerr != nil {
-		return nil, aerr
+	st, err := c.requestToken(req)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.Get(st)
+	if err != nil || token != nil {
+		return token, err
+	}
+	if token, err = Decode(st); err != nil {
+		return nil, err
 	}
-	return token, err
+	if _, err = c.Put(token); err != nil {
+		return nil, err
+	}
+	return token, nil
 }
 
 // RequestVerify tries to retrieve a token from the cache by
-// the requests authorization header. Otherwise it verifies it and
-// puts it.
-func (c *Cache) RequestVerify(req *http.Request, key Key) (*JWT, error) {
-	var token *JWT
-	var err error
-	aerr := c.doSync(func() {
-		var st string
-		if st, err = c.requestToken(req); err != nil {
-			return
-		}
-		if token, err = c.Get(st); err != nil {
-			return
-		}
-		if token, err = Verify(st, key); err != nil {
-			return
-		}
-		_, err = c.Put(token)
-	}, defaultTimeout)
-	if aerr != nil {
-		return nil, aerr
+// the requests authorization header like Verify. Otherwise it
+// verifies it and puts it. The options are passed to the
+// verification.
+func (c *Cache) RequestVerify(req *http.Request, key Key, options ...Option) (*JWT, error) {
+	st, err := c.requestToken(req)
+	if err != nil {
+		return nil, err
 	}
-	return token, err
+	return c.Verify(st, key, options...)
 }
 
 // Put adds a token to the cache and return the total number of entries.
+// Tokens created by encoding or verification are stored together with
+// the thumbprint of their key, also in the store if one is set. Tokens
+// with legacy ECDSA signatures are not stored. Failures of the store
+// are not returned.
 func (c *Cache) Put(token *JWT) (int, error) {
-	var l int
-	err := c.doSync(func() {
-		if c.entries == nil {
-			l = 0
-			return
+	if err := c.checkStopped(); err != nil {
+		return 0, err
+	}
+	var thumbprint []byte
+	if token.key != nil {
+		thumbprint, _ = keyThumbprint(token.key)
+	}
+	now := c.clock.Now()
+	if !token.IsValidAt(now, c.leeway) {
+		return int(c.count.Load()), nil
+	}
+	legacy := hasLegacySignature(token)
+	c.putLocal(&cacheEntry{token: token, thumbprint: thumbprint, legacy: legacy}, now)
+	if c.store != nil && thumbprint != nil && !legacy {
+		ttl := c.ttl
+		if exp, ok := token.claims.Expiration(); ok {
+			ttl = min(ttl, exp.Add(c.leeway).Sub(now))
 		}
-		if token.IsValid(c.leeway) {
-			c.entries.Put(token.String(), &cacheEntry{token, time.Now()})
-			lenEntries := c.entries.Count()
-			if lenEntries > c.maxEntries {
-				ttl := int64(c.ttl) / int64(lenEntries) * int64(c.maxEntries)
-				c.cleanup(time.Duration(ttl))
+		if ttl > 0 {
+			st := token.String()
+			if err := c.store.Store(c.ctx, st, c.digest(st, thumbprint), ttl); err != nil {
+				c.stats.storeFailed(fmt.Errorf("cannot store the token: %w", err))
 			}
 		}
-		l = c.entries.Count()
-	}, defaultTimeout)
-	return l, err
+	}
+	return int(c.count.Load()), nil
+}
+
+// putLocal adds the entry of a valid token to the shards.
+func (c *Cache) putLocal(entry *cacheEntry, now time.Time) {
+	st := entry.token.String()
+	shard := c.shard(st)
+	shard.mu.Lock()
+	entry.accessed = now
+	// Elements are never changed so that the eviction can read
+	// them without the lock of the shard.
+	if element, ok := shard.entries.Get(st); ok {
+		c.remove(shard, element)
+	}
+	c.lruMu.Lock()
+	shard.entries.Put(st, c.lru.PushFront(entry))
+	c.lruMu.Unlock()
+	c.count.Add(1)
+	c.bytes.Add(int64(len(st)))
+	shard.mu.Unlock()
+	if c.exceedsLimits() {
+		c.evict()
+	}
+}
+
+// exceedsLimits checks if the number of entries or their total
+// length are above the limits.
+func (c *Cache) exceedsLimits() bool {
+	return c.count.Load() > int64(c.maxEntries) || (c.maxBytes > 0 && c.bytes.Load() > int64(c.maxBytes))
+}
+
+// evict removes the least recently used entries while the limits
+// of the cache are exceeded.
+func (c *Cache) evict() {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	evicted := 0
+	for c.exceedsLimits() {
+		c.lruMu.Lock()
+		back := c.lru.Back()
+		c.lruMu.Unlock()
+		if back == nil {
+			break
+		}
+		// Remove it unless it has been accessed or removed
+		// in the meantime.
+		st := back.Value.(*cacheEntry).token.String()
+		shard := c.shard(st)
+		shard.mu.Lock()
+		if element, ok := shard.entries.Get(st); ok && element == back && c.isBack(element) {
+			c.remove(shard, element)
+			evicted++
+		}
+		shard.mu.Unlock()
+	}
+	c.stats.evicted(evicted)
+}
+
+// isBack checks if the element is the least recently used one.
+func (c *Cache) isBack(element *list.Element) bool {
+	c.lruMu.Lock()
+	defer c.lruMu.Unlock()
+	return c.lru.Back() == element
+}
+
+// get retrieves a valid entry from the cache. If it is not found
+// locally it is loaded from the store if one is set.
+func (c *Cache) get(st string) (*cacheEntry, error) {
+	if err := c.checkStopped(); err != nil {
+		return nil, err
+	}
+	if entry := c.getLocal(st); entry != nil || c.store == nil {
+		return entry, nil
+	}
+	digest, err := c.store.Load(c.ctx, st, c.ttl)
+	if err != nil {
+		c.stats.storeFailed(fmt.Errorf("cannot load the token: %w", err))
+		return nil, nil
+	}
+	if digest == nil {
+		return nil, nil
+	}
+	token, err := Decode(st)
+	if err != nil {
+		return nil, nil
+	}
+	now := c.clock.Now()
+	if !token.IsValidAt(now, c.leeway) {
+		return nil, nil
+	}
+	entry := &cacheEntry{token: token, digest: digest}
+	c.putLocal(entry, now)
+	return &cacheEntry{token: token, digest: digest, accessed: now}, nil
+}
+
+// getLocal retrieves a valid entry from the shards.
+func (c *Cache) getLocal(st string) *cacheEntry {
+	shard := c.shard(st)
+	shard.mu.Lock()
+	element, ok := shard.entries.Get(st)
+	if !ok {
+		shard.mu.Unlock()
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	now := c.clock.Now()
+	if !entry.token.IsValidAt(now, c.leeway) {
+		// Remove invalid token.
+		c.remove(shard, element)
+		shard.mu.Unlock()
+		c.stats.expired(1)
+		return nil
+	}
+	entry.accessed = now
+	c.lruMu.Lock()
+	c.lru.MoveToFront(element)
+	c.lruMu.Unlock()
+	copied := *entry
+	shard.mu.Unlock()
+	return &copied
 }
 
 // Cleanup manually tells the cache to cleanup.
 func (c *Cache) Cleanup() error {
-	return c.doSync(func() {
-		if c.entries == nil {
-			return
-		}
-		c.cleanup(c.ttl)
-	}, defaultTimeout)
+	if err := c.checkStopped(); err != nil {
+		return err
+	}
+	c.cleanup(c.ttl)
+	return nil
 }
 
 // requestToken retrieves an authentication token out of a request.
 func (c *Cache) requestToken(req *http.Request) (string, error) {
 	authorization := req.Header.Get("Authorization")
 	if authorization == "" {
-		return "", fmt.Errorf("request contains no authorization header")
+		return "", ErrMissingHeader
 	}
 	fields := strings.Fields(authorization)
 	if len(fields) != 2 || fields[0] != "Bearer" {
-		return "", fmt.Errorf("invalid authorization header: %q", authorization)
+		return "", fmt.Errorf("%w: %q", ErrInvalidAuthorization, authorization)
 	}
 	return fields[1], nil
 }
 
+// Stats returns a snapshot of the cache statistics.
+func (c *Cache) Stats() CacheStats {
+	return c.stats.snapshot(int(c.count.Load()))
+}
+
 // cleanup checks for invalid or unused tokens.
 func (c *Cache) cleanup(ttl time.Duration) {
-	valids := swiss.NewMap[string, *cacheEntry](42)
-	now := time.Now()
-	c.entries.Iter(func(key string, entry *cacheEntry) bool {
-		if entry.token.IsValid(c.leeway) {
-			if entry.accessed.Add(ttl).After(now) {
-				// Everything fine.
-				valids.Put(key, entry)
+	start := time.Now()
+	now := c.clock.Now()
+	expired := 0
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		var elements []*list.Element
+		shard.entries.Iter(func(_ string, element *list.Element) bool {
+			entry := element.Value.(*cacheEntry)
+			if !entry.token.IsValidAt(now, c.leeway) || !entry.accessed.Add(ttl).After(now) {
+				elements = append(elements, element)
 			}
+			return false
+		})
+		for _, element := range elements {
+			c.remove(shard, element)
 		}
-		return true
-	})
-	c.entries = valids
+		expired += len(elements)
+		shard.mu.Unlock()
+	}
+	c.stats.expired(expired)
+	c.stats.cleanedUp(time.Since(start))
 }
 
-// doSync performs a function in the backend synchronously.
-func (c *Cache) doSync(action func(), timeout time.Duration) error {
-	donec := make(chan struct{})
-	c.actionc <- func() {
-		action()
-		close(donec)
+// clear removes all tokens.
+func (c *Cache) clear() {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		var elements []*list.Element
+		shard.entries.Iter(func(_ string, element *list.Element) bool {
+			elements = append(elements, element)
+			return false
+		})
+		for _, element := range elements {
+			c.remove(shard, element)
+		}
+		shard.mu.Unlock()
 	}
-	select {
-	case <-donec:
-		return nil
-	case <-time.After(timeout):
-		return fmt.Errorf("cache action timeout")
+}
+
+// remove removes the element from the shard. The shard has to
+// be locked.
+func (c *Cache) remove(shard *cacheShard, element *list.Element) {
+	st := element.Value.(*cacheEntry).token.String()
+	c.lruMu.Lock()
+	c.lru.Remove(element)
+	c.lruMu.Unlock()
+	shard.entries.Delete(st)
+	c.count.Add(-1)
+	c.bytes.Add(-int64(len(st)))
+}
+
+// shard returns the shard responsible for the token.
+func (c *Cache) shard(st string) *cacheShard {
+	return &c.shards[maphash.String(c.seed, st)%uint64(len(c.shards))]
+}
+
+// checkStopped returns an error if the context of the cache is done.
+func (c *Cache) checkStopped() error {
+	if c.ctx.Err() != nil {
+		return ErrCacheStopped
 	}
+	return nil
 }
 
-// backend is the goroutine of the cache.
+// isVerifiedWith checks if the entry has been verified with a key
+// equivalent to the passed one and returns it. In case of a KeySource
+// one of its keys for the token has to be equivalent.
+func (c *Cache) isVerifiedWith(entry *cacheEntry, key Key) (Key, bool) {
+	if entry.thumbprint == nil && entry.digest == nil {
+		return nil, false
+	}
+	if source, ok := key.(KeySource); ok {
+		kid, _ := entry.token.header.KeyID()
+		jwks, err := source.LookupKeys(kid, entry.token.algorithm)
+		if err != nil {
+			return nil, false
+		}
+		for _, jwk := range jwks {
+			if thumbprint, ok := keyThumbprint(jwk); ok && c.matches(entry, thumbprint) {
+				return jwk.verificationJWK(), true
+			}
+		}
+		return nil, false
+	}
+	unbound, err := unbindKey(key, entry.token.algorithm)
+	if err != nil {
+		return nil, false
+	}
+	thumbprint, ok := keyThumbprint(unbound)
+	return key, ok && c.matches(entry, thumbprint)
+}
+
+// matches checks if the thumbprint is the one of the entry or, for
+// entries loaded from the store, the one of its digest.
+func (c *Cache) matches(entry *cacheEntry, thumbprint []byte) bool {
+	if entry.thumbprint != nil {
+		return bytes.Equal(thumbprint, entry.thumbprint)
+	}
+	return hmac.Equal(c.digest(entry.token.String(), thumbprint), entry.digest)
+}
+
+// digest returns the HMAC of the token and the thumbprint keyed
+// with the secret of the store.
+func (c *Cache) digest(st string, thumbprint []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(lengthPrefixed(nil, []byte(st)))
+	mac.Write(thumbprint)
+	return mac.Sum(nil)
+}
+
+// checkCached checks a cached token against the options like the
+// verification does, except the signature.
+func checkCached(token *JWT, o *options) error {
+	if _, err := lookupMethod(token.algorithm); err != nil {
+		return fmt.Errorf("cannot verify the header: %w", err)
+	}
+	if !o.permits(token.algorithm) {
+		return fmt.Errorf("cannot verify the header: %w", &AlgorithmError{Algorithm: token.algorithm, Err: ErrAlgorithmNotPermitted})
+	}
+	if err := checkCritical(token.header, o.critical); err != nil {
+		return fmt.Errorf("cannot verify the header: %w", err)
+	}
+	if o.validator != nil {
+		if err := o.validator.ValidateAt(token.claims, o.clock.Now()); err != nil {
+			return fmt.Errorf("cannot verify the claims: %w", err)
+		}
+	}
+	return nil
+}
+
+// hasLegacySignature checks if the token is signed with an ECDSA
+// algorithm and its signature is not the concatenation of R and S.
+func hasLegacySignature(token *JWT) bool {
+	curve := token.algorithm.curve()
+	if curve == nil {
+		return false
+	}
+	encoded := token.token[strings.LastIndexByte(token.token, '.')+1:]
+	size := (curve.Params().BitSize + 7) / 8
+	return base64.RawURLEncoding.DecodedLen(len(encoded)) != 2*size
+}
+
+// keyThumbprint returns the JWK thumbprint identifying a key used for
+// encoding or verification. Private keys and signers are identified
+// by their public key.
+func keyThumbprint(key Key) ([]byte, bool) {
+	switch k := key.(type) {
+	case *BoundKey:
+		return keyThumbprint(k.Key)
+	case *JWK:
+		thumbprint, err := k.Thumbprint(crypto.SHA256)
+		return thumbprint, err == nil
+	}
+	thumbprint, err := (&JWK{Key: key}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, false
+		}
+		thumbprint, err = (&JWK{Key: signer.Public()}).Thumbprint(crypto.SHA256)
+	}
+	return thumbprint, err == nil
+}
+
+// backend is the goroutine of the cache running the cleanup.
 func (c *Cache) backend() {
 	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-c.ctx.Done():
-			c.entries = swiss.NewMap[string, *cacheEntry](42)
-			ticker.Stop()
+			c.clear()
 			return
-		case action := <-c.actionc:
-			action()
 		case <-ticker.C:
-			if c.entries != nil {
-				c.cleanup(c.ttl)
-			}
+			c.cleanup(c.ttl)
 		}
 	}
 }
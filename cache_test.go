@@ -9,7 +9,16 @@ package jwt_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -115,6 +124,123 @@ func TestCacheLoad(t *testing.T) {
 	}
 }
 
+// TestCacheEviction verifies the eviction of the least recently
+// used tokens when the limits are reached.
+func TestCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 64)
+	key := []byte("secret")
+	encode := func(i int) *jwt.JWT {
+		claims := initClaims()
+		claims.Set("index", fmt.Sprintf("%04d", i))
+		token, err := jwt.Encode(claims, key, jwt.HS512)
+		verify.NoError(t, err)
+		return token
+	}
+	// The cache keeps as many tokens as configured.
+	for i := 0; i < 64; i++ {
+		size, err := cache.Put(encode(i))
+		verify.NoError(t, err)
+		verify.Equal(t, size, i+1)
+	}
+	verify.Equal(t, cache.Stats().Evictions, uint64(0))
+	recent := encode(0)
+	for i := 64; i < 500; i++ {
+		// Keep the first token recently used.
+		token, err := cache.Get(recent.String())
+		verify.NoError(t, err)
+		verify.NotNil(t, token)
+		verify.Equal(t, token.String(), recent.String())
+		size, err := cache.Put(encode(i))
+		verify.NoError(t, err)
+		verify.Equal(t, size, 64)
+	}
+	// The least recently used tokens are evicted first.
+	for i := 500 - 63; i < 500; i++ {
+		token, err := cache.Get(encode(i).String())
+		verify.NoError(t, err)
+		verify.NotNil(t, token)
+	}
+	// Limit the bytes to less tokens than entries.
+	size := len(recent.String())
+	cache = jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 64, jwt.WithMaxBytes(10*size))
+	var count int
+	var err error
+	for i := 0; i < 100; i++ {
+		count, err = cache.Put(encode(i))
+		verify.NoError(t, err)
+		verify.Equal(t, count, min(i+1, 10))
+	}
+	for i := 90; i < 100; i++ {
+		token, err := cache.Get(encode(i).String())
+		verify.NoError(t, err)
+		verify.NotNil(t, token)
+	}
+	// Tokens larger than the budget are not kept.
+	cache = jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 64, jwt.WithMaxBytes(size-1))
+	count, err = cache.Put(recent)
+	verify.NoError(t, err)
+	verify.Equal(t, count, 0)
+}
+
+// TestCacheStats verifies the statistics and the observer of
+// the cache.
+func TestCacheStats(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	observer := &testObserver{}
+	cache := jwt.NewCache(ctx, time.Minute, time.Second, time.Hour, 1,
+		jwt.WithClock(clock), jwt.WithCacheObserver(observer))
+	key := []byte("secret")
+	encode := func(sub string, lifetime time.Duration) *jwt.JWT {
+		claims := initClaims()
+		claims.SetSubject(sub)
+		claims.SetExpiration(clock.Now().Add(lifetime))
+		token, err := jwt.Encode(claims, key, jwt.HS512)
+		verify.NoError(t, err)
+		return token
+	}
+	tokenA := encode("a", time.Hour)
+	tokenB := encode("b", time.Hour)
+	tokenC := encode("c", time.Minute)
+	// Hits, misses, and evictions.
+	_, err := cache.Put(tokenA)
+	verify.NoError(t, err)
+	_, err = cache.Get(tokenA.String())
+	verify.NoError(t, err)
+	_, err = cache.Get("is.not.there")
+	verify.NoError(t, err)
+	_, err = cache.Put(tokenB)
+	verify.NoError(t, err)
+	_, err = cache.Verify(tokenB.String(), key)
+	verify.NoError(t, err)
+	_, err = cache.Verify(tokenB.String(), []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	_, err = cache.Put(tokenC)
+	verify.NoError(t, err)
+	// Expirations by access and cleanup.
+	clock.Advance(2 * time.Minute)
+	_, err = cache.Get(tokenC.String())
+	verify.NoError(t, err)
+	_, err = cache.Put(tokenB)
+	verify.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	verify.NoError(t, cache.Cleanup())
+	stats := cache.Stats()
+	verify.Equal(t, stats.Entries, 0)
+	verify.Equal(t, stats.Hits, uint64(2))
+	verify.Equal(t, stats.Misses, uint64(3))
+	verify.Equal(t, stats.Evictions, uint64(2))
+	verify.Equal(t, stats.Expirations, uint64(2))
+	verify.Equal(t, stats.Cleanups, uint64(1))
+	verify.Equal(t, observer.hits.Load(), int64(stats.Hits))
+	verify.Equal(t, observer.misses.Load(), int64(stats.Misses))
+	verify.Equal(t, observer.evictions.Load(), int64(stats.Evictions))
+	verify.Equal(t, observer.expirations.Load(), int64(stats.Expirations))
+	verify.Equal(t, observer.cleanups.Load(), int64(stats.Cleanups))
+	verify.Equal(t, time.Duration(observer.cleanupDuration.Load()), stats.CleanupDuration)
+}
+
 // TestCacheContext verifies the cache stopping by context.
 func TestCacheContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -128,12 +254,231 @@ func TestCacheContext(t *testing.T) {
 	// Now cancel and test to get jwt.
 	cancel()
 	time.Sleep(10 * time.Millisecond)
-	jwt := jwtIn.String()
-	jwtOut, err := cache.Get(jwt)
-	verify.ErrorContains(t, err, "cache action timeout")
+	jwtOut, err := cache.Get(jwtIn.String())
+	verify.IsError(t, err, jwt.ErrCacheStopped)
 	verify.True(t, jwtOut == nil)
 }
 
+// TestCacheVerify verifies that only tokens verified with an
+// equivalent key are returned by the verification.
+func TestCacheVerify(t *testing.T) {
+	ctx := context.Background()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10)
+	key := []byte("secret")
+	jwtIn, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	st := jwtIn.String()
+	// Decoded tokens are no verified ones.
+	jwtDec, err := jwt.Decode(st)
+	verify.NoError(t, err)
+	_, err = cache.Put(jwtDec)
+	verify.NoError(t, err)
+	_, err = cache.Verify(st, []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	jwtOut, err := cache.Verify(st, key)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	// Now cached as verified, but only for equivalent keys.
+	_, err = cache.Verify(st, []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	_, err = cache.Verify(st, jwt.BindKey(key, jwt.HS256))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	hsJWK, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	jwtOut, err = cache.Verify(st, hsJWK)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	// Options are checked for cached tokens too.
+	_, err = cache.Verify(st, key, jwt.WithAlgorithms(jwt.HS256))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = cache.Verify(st, key, jwt.WithValidator(jwt.NewValidator(jwt.ExpectSubject("foo"))))
+	verify.IsError(t, err, jwt.ErrInvalidClaim)
+	// Disabled algorithms too.
+	jwt.DisableAlgorithm(jwt.HS512)
+	t.Cleanup(func() { jwt.EnableAlgorithm(jwt.HS512) })
+	_, err = cache.Verify(st, key)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+}
+
+// TestCacheVerifyLegacy verifies that tokens with legacy ECDSA
+// signatures are only returned in legacy mode.
+func TestCacheVerifyLegacy(t *testing.T) {
+	ctx := context.Background()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10)
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	data := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234567890"}`))
+	digest := sha256.Sum256([]byte(data))
+	derSignature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	verify.NoError(t, err)
+	st := data + "." + base64.RawURLEncoding.EncodeToString(derSignature)
+	_, err = cache.Verify(st, privateKey.Public(), jwt.WithLegacyECDSA())
+	verify.NoError(t, err)
+	_, err = cache.Verify(st, privateKey.Public(), jwt.WithLegacyECDSA())
+	verify.NoError(t, err)
+	verify.Equal(t, cache.Stats().Hits, uint64(1))
+	// Without legacy mode the cached token is verified again.
+	_, err = cache.Verify(st, privateKey.Public())
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	verify.Equal(t, cache.Stats().Hits, uint64(1))
+}
+
+// TestCacheVerifyKeys verifies the verification of tokens encoded
+// with private keys and verified with public keys or key sets.
+func TestCacheVerifyKeys(t *testing.T) {
+	ctx := context.Background()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10)
+	rsKey, esKey, hsKey := generateJWKs(t)
+	jwtIn, err := jwt.Encode(initClaims(), esKey, esKey.Algorithm)
+	verify.NoError(t, err)
+	_, err = cache.Put(jwtIn)
+	verify.NoError(t, err)
+	st := jwtIn.String()
+	esPublic := publicJWK(t, esKey)
+	jwtOut, err := cache.Verify(st, esPublic)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	outKey, err := jwtOut.Key()
+	verify.NoError(t, err)
+	verify.Equal(t, outKey, jwt.Key(esPublic))
+	_, err = cache.Verify(st, publicJWK(t, rsKey))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	jwtOut, err = cache.Verify(st, jwt.NewKeySet(publicJWK(t, rsKey), esPublic, hsKey))
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	outKey, err = jwtOut.Key()
+	verify.NoError(t, err)
+	outJWK, ok := outKey.(*jwt.JWK)
+	verify.True(t, ok)
+	verify.False(t, outJWK.IsPrivate())
+	_, err = cache.Verify(st, jwt.NewKeySet(publicJWK(t, rsKey), hsKey))
+	verify.IsError(t, err, jwt.ErrKeyNotFound)
+}
+
+// TestCacheRequest verifies the decoding and verification of
+// request tokens.
+func TestCacheRequest(t *testing.T) {
+	ctx := context.Background()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10)
+	key := []byte("secret")
+	jwtIn, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req = jwt.RequestAdd(req, jwtIn)
+	jwtOut, err := cache.RequestDecode(req)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), jwtIn.String())
+	_, err = cache.RequestVerify(req, []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	jwtOut, err = cache.RequestVerify(req, key)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), jwtIn.String())
+	jwtOut, err = cache.RequestDecode(req)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), jwtIn.String())
+	// Invalid requests.
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	_, err = cache.RequestVerify(req, key)
+	verify.IsError(t, err, jwt.ErrMissingHeader)
+	req.Header.Set("Authorization", "Basic foo")
+	_, err = cache.RequestDecode(req)
+	verify.IsError(t, err, jwt.ErrInvalidAuthorization)
+}
+
+// TestCacheConcurrency verifies the concurrent usage of all cache
+// methods. It is intended to be run with the race detector.
+func TestCacheConcurrency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, 10*time.Millisecond, 50)
+	key := []byte("secret")
+	tokens := make([]*jwt.JWT, 20)
+	for i := range tokens {
+		claims := initClaims()
+		claims.Set("index", i)
+		token, err := jwt.Encode(claims, key, jwt.HS512)
+		verify.NoError(t, err)
+		tokens[i] = token
+	}
+	var wg sync.WaitGroup
+	errc := make(chan error, 64)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				token := tokens[(g+i)%len(tokens)]
+				req := jwt.RequestAdd(httptest.NewRequest(http.MethodGet, "http://localhost/", nil), token)
+				var out *jwt.JWT
+				var err error
+				switch i % 6 {
+				case 0:
+					_, err = cache.Put(token)
+				case 1:
+					_, err = cache.Get(token.String())
+				case 2:
+					out, err = cache.Verify(token.String(), key)
+				case 3:
+					out, err = cache.RequestDecode(req)
+				case 4:
+					out, err = cache.RequestVerify(req, key)
+				case 5:
+					err = cache.Cleanup()
+				}
+				if err == nil && out != nil && out.String() != token.String() {
+					err = fmt.Errorf("got token %q, want %q", out, token)
+				}
+				if err != nil {
+					errc <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		verify.NoError(t, err)
+	}
+	size, err := cache.Put(tokens[0])
+	verify.NoError(t, err)
+	verify.True(t, size > 0 && size <= len(tokens))
+	// Stopping while in use.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, _ = cache.Verify(tokens[i%len(tokens)].String(), key)
+		}
+	}()
+	cancel()
+	wg.Wait()
+	_, err = cache.Get(tokens[0].String())
+	verify.IsError(t, err, jwt.ErrCacheStopped)
+}
+
+// testObserver counts the events of a cache.
+type testObserver struct {
+	hits            atomic.Int64
+	misses          atomic.Int64
+	evictions       atomic.Int64
+	expirations     atomic.Int64
+	cleanups        atomic.Int64
+	cleanupDuration atomic.Int64
+	storeErrors     atomic.Int64
+}
+
+func (o *testObserver) Hit()          { o.hits.Add(1) }
+func (o *testObserver) Miss()         { o.misses.Add(1) }
+func (o *testObserver) Evicted(n int) { o.evictions.Add(int64(n)) }
+func (o *testObserver) Expired(n int) { o.expirations.Add(int64(n)) }
+func (o *testObserver) CleanedUp(d time.Duration) {
+	o.cleanups.Add(1)
+	o.cleanupDuration.Add(int64(d))
+}
+func (o *testObserver) StoreFailed(error) { o.storeErrors.Add(1) }
+
 // initClaims creates test claims.
 func initClaims() jwt.Claims {
 	c := jwt.NewClaims()
@@ -0,0 +1,134 @@
+// Tideland Go JSON Web Token - Cache Statistics
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats is a snapshot of the statistics of a Cache. The
+// CleanupDuration is the sum of the durations of all cleanups.
+type CacheStats struct {
+	Entries         int
+	Hits            uint64
+	Misses          uint64
+	Evictions       uint64
+	Expirations     uint64
+	Cleanups        uint64
+	CleanupDuration time.Duration
+	StoreErrors     uint64
+}
+
+// CacheObserver is notified about the events of a Cache, e.g. to
+// export them as metrics. The methods are called synchronously and
+// concurrently, so they have to be fast and safe for concurrent use.
+type CacheObserver interface {
+	// Hit is called when a token is served from the cache.
+	Hit()
+
+	// Miss is called when a token is not found in the cache or
+	// cannot be served from it.
+	Miss()
+
+	// Evicted is called when tokens are evicted due to the limits.
+	Evicted(n int)
+
+	// Expired is called when tokens are removed because they are
+	// invalid or unused for longer than the ttl.
+	Expired(n int)
+
+	// CleanedUp is called after each cleanup with its duration.
+	CleanedUp(duration time.Duration)
+
+	// StoreFailed is called when loading from or storing into the
+	// CacheStore fails. The cache continues without the store.
+	StoreFailed(err error)
+}
+
+// cacheStats collects the statistics of a cache and notifies the
+// optional observer.
+type cacheStats struct {
+	observer        CacheObserver
+	hits            atomic.Uint64
+	misses          atomic.Uint64
+	evictions       atomic.Uint64
+	expirations     atomic.Uint64
+	cleanups        atomic.Uint64
+	cleanupDuration atomic.Int64
+	storeErrors     atomic.Uint64
+}
+
+// hit counts a cache hit.
+func (s *cacheStats) hit() {
+	s.hits.Add(1)
+	if s.observer != nil {
+		s.observer.Hit()
+	}
+}
+
+// miss counts a cache miss.
+func (s *cacheStats) miss() {
+	s.misses.Add(1)
+	if s.observer != nil {
+		s.observer.Miss()
+	}
+}
+
+// evicted counts evicted tokens.
+func (s *cacheStats) evicted(n int) {
+	if n == 0 {
+		return
+	}
+	s.evictions.Add(uint64(n))
+	if s.observer != nil {
+		s.observer.Evicted(n)
+	}
+}
+
+// expired counts expired tokens.
+func (s *cacheStats) expired(n int) {
+	if n == 0 {
+		return
+	}
+	s.expirations.Add(uint64(n))
+	if s.observer != nil {
+		s.observer.Expired(n)
+	}
+}
+
+// cleanedUp counts a cleanup and adds its duration.
+func (s *cacheStats) cleanedUp(duration time.Duration) {
+	s.cleanups.Add(1)
+	s.cleanupDuration.Add(int64(duration))
+	if s.observer != nil {
+		s.observer.CleanedUp(duration)
+	}
+}
+
+// storeFailed counts a failed access to the store.
+func (s *cacheStats) storeFailed(err error) {
+	s.storeErrors.Add(1)
+	if s.observer != nil {
+		s.observer.StoreFailed(err)
+	}
+}
+
+// snapshot returns the current statistics.
+func (s *cacheStats) snapshot(entries int) CacheStats {
+	return CacheStats{
+		Entries:         entries,
+		Hits:            s.hits.Load(),
+		Misses:          s.misses.Load(),
+		Evictions:       s.evictions.Load(),
+		Expirations:     s.expirations.Load(),
+		Cleanups:        s.cleanups.Load(),
+		CleanupDuration: time.Duration(s.cleanupDuration.Load()),
+		StoreErrors:     s.storeErrors.Load(),
+	}
+}
@@ -0,0 +1,27 @@
+// Tideland Go JSON Web Token - Cache Store
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"context"
+	"time"
+)
+
+// CacheStore shares the verification results of caches, e.g. between
+// the replicas of a service. For each verified token it stores a digest
+// of the token and the key used for the verification. The digest is
+// keyed with the secret shared by the caches, so it reveals nothing
+// about the key and cannot be forged without the secret.
+type CacheStore interface {
+	// Load returns the digest stored for the token and extends
+	// its time to live. Unknown tokens return nil without an error.
+	Load(ctx context.Context, token string, ttl time.Duration) ([]byte, error)
+
+	// Store stores the digest for the token for the time to live.
+	Store(ctx context.Context, token string, digest []byte, ttl time.Duration) error
+}
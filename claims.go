@@ -8,6 +8,7 @@
 package jwt
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -23,6 +24,15 @@ func NewClaims() Claims {
 	return Claims{}
 }
 
+// copyClaims returns a shallow copy of the claims.
+func copyClaims(c Claims) Claims {
+	cc := make(Claims, len(c)+2)
+	for key, value := range c {
+		cc[key] = value
+	}
+	return cc
+}
+
 // Len returns the number of entries in the claims.
 func (c Claims) Len() int {
 	if c == nil {
@@ -74,18 +84,34 @@ func (c Claims) GetBool(key string) (bool, bool) {
 
 // GetInt retrieves an integer value.
 func (c Claims) GetInt(key string) (int, bool) {
+	i, ok := c.GetInt64(key)
+	return int(i), ok
+}
+
+// GetInt64 retrieves an integer value. Numbers decoded from
+// JSON keep their full precision.
+func (c Claims) GetInt64(key string) (int64, bool) {
 	value, ok := c.Get(key)
 	if !ok {
 		return 0, false
 	}
 	switch v := value.(type) {
 	case int:
+		return int64(v), true
+	case int64:
 		return v, true
 	case float64:
-		return int(v), true
+		return int64(v), true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil {
+			return int64(f), true
+		}
 	case string:
 		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
-			return int(i), true
+			return i, true
 		}
 	}
 	return 0, false
@@ -100,8 +126,14 @@ func (c Claims) GetFloat64(key string) (float64, bool) {
 	switch v := value.(type) {
 	case int:
 		return float64(v), true
+	case int64:
+		return float64(v), true
 	case float64:
 		return v, true
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, true
+		}
 	case string:
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
 			return f, true
@@ -110,8 +142,8 @@ func (c Claims) GetFloat64(key string) (float64, bool) {
 	return 0.0, false
 }
 
-// GetTime retrieves a time value. Int, int32, int64,
-// and float64 are valid types for the conversion. In case
+// GetTime retrieves a time value. Int, int32, int64, float64,
+// and JSON numbers are valid types for the conversion. In case
 // a string it is interpreted as RFC 3339 formatted time.
 func (c Claims) GetTime(key string) (time.Time, bool) {
 	value, ok := c.Get(key)
@@ -127,6 +159,14 @@ func (c Claims) GetTime(key string) (time.Time, bool) {
 		return time.Unix(v, 0), true
 	case float64:
 		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return time.Unix(i, 0), true
+		}
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(int64(f), 0), true
+		}
+		return time.Time{}, false
 	case string:
 		t, err := time.Parse(time.RFC3339, v)
 		if err != nil {
@@ -373,8 +413,15 @@ func (c Claims) DeleteSubject() string {
 // the current time. The leeway is subtracted from the
 // "nbf" time to account for clock skew.
 func (c Claims) IsAlreadyValid(leeway time.Duration) bool {
+	return c.IsAlreadyValidAt(time.Now(), leeway)
+}
+
+// IsAlreadyValidAt checks if the claim "nbf" is after
+// the passed time. The leeway is subtracted from the
+// "nbf" time to account for clock skew.
+func (c Claims) IsAlreadyValidAt(now time.Time, leeway time.Duration) bool {
 	if nbf, ok := c.NotBefore(); ok {
-		return time.Now().After(nbf.Add(-leeway))
+		return now.After(nbf.Add(-leeway))
 	}
 	return true
 }
@@ -383,8 +430,15 @@ func (c Claims) IsAlreadyValid(leeway time.Duration) bool {
 // the current time. The leeway is added to the "exp"
 // time to account for clock skew.
 func (c Claims) IsStillValid(leeway time.Duration) bool {
+	return c.IsStillValidAt(time.Now(), leeway)
+}
+
+// IsStillValidAt checks if the claim "exp" is before
+// the passed time. The leeway is added to the "exp"
+// time to account for clock skew.
+func (c Claims) IsStillValidAt(now time.Time, leeway time.Duration) bool {
 	if exp, ok := c.Expiration(); ok {
-		return time.Now().Before(exp.Add(leeway))
+		return now.Before(exp.Add(leeway))
 	}
 	return true
 }
@@ -392,9 +446,15 @@ func (c Claims) IsStillValid(leeway time.Duration) bool {
 // IsValid is a combination of IsAlreadyValid() and
 // IsStillValid().
 func (c Claims) IsValid(leeway time.Duration) bool {
+	return c.IsValidAt(time.Now(), leeway)
+}
+
+// IsValidAt is a combination of IsAlreadyValidAt() and
+// IsStillValidAt().
+func (c Claims) IsValidAt(now time.Time, leeway time.Duration) bool {
 	// First check expiration as it is more likely.
-	if c.IsStillValid(leeway) {
-		return c.IsAlreadyValid(leeway)
+	if c.IsStillValidAt(now, leeway) {
+		return c.IsAlreadyValidAt(now, leeway)
 	}
 	return false
 }
@@ -407,19 +467,23 @@ func (c Claims) MarshalJSON() ([]byte, error) {
 	}
 	b, err := json.Marshal(map[string]interface{}(c))
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling claims to JSON: %v", err)
+		return nil, fmt.Errorf("error marshalling claims to JSON: %w", err)
 	}
 	return b, nil
 }
 
-// UnmarshalJSON implements the json.Marshaller interface.
+// UnmarshalJSON implements the json.Marshaller interface. Numbers
+// are stored as json.Number.
 func (c *Claims) UnmarshalJSON(b []byte) error {
 	if b == nil {
 		return nil
 	}
+	// Numbers are kept as json.Number to not lose precision.
 	raw := map[string]interface{}(*c)
-	if err := json.Unmarshal(b, &raw); err != nil {
-		return fmt.Errorf("error unmarshalling claims from JSON: %v", err)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return fmt.Errorf("error unmarshalling claims from JSON: %w", err)
 	}
 	*c = Claims(raw)
 	return nil
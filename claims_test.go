@@ -147,6 +147,51 @@ func TestClaimsInt(t *testing.T) {
 	verify.False(t, ok)
 }
 
+// TestClaimsInt64 verifies the int64 operations on claims
+// including the precision of decoded numbers.
+func TestClaimsInt64(t *testing.T) {
+	var c jwt.Claims
+	err := json.Unmarshal([]byte(`{"id":1234567890123456789,"float":4711.5,"time":1700000000}`), &c)
+	verify.NoError(t, err)
+	id, ok := c.GetInt64("id")
+	verify.True(t, ok)
+	verify.Equal(t, id, int64(1234567890123456789))
+	str, ok := c.GetString("id")
+	verify.True(t, ok)
+	verify.Equal(t, str, "1234567890123456789")
+	i, ok := c.GetInt("float")
+	verify.True(t, ok)
+	verify.Equal(t, i, 4711)
+	f, ok := c.GetFloat64("float")
+	verify.True(t, ok)
+	verify.Equal(t, f, 4711.5)
+	tm, ok := c.GetTime("time")
+	verify.True(t, ok)
+	verify.Equal(t, tm.Unix(), int64(1700000000))
+	// Precision survives a token round trip.
+	key := []byte("secret")
+	tokenEnc, err := jwt.Encode(c, key, jwt.HS512)
+	verify.NoError(t, err)
+	tokenVer, err := jwt.Verify(tokenEnc.String(), key)
+	verify.NoError(t, err)
+	id, ok = tokenVer.Claims().GetInt64("id")
+	verify.True(t, ok)
+	verify.Equal(t, id, int64(1234567890123456789))
+	// Set values.
+	c = jwt.NewClaims()
+	c.Set("foo", int64(4711))
+	c.Set("bar", "4712")
+	c.Set("yadda", "nope")
+	foo, ok := c.GetInt64("foo")
+	verify.True(t, ok)
+	verify.Equal(t, foo, int64(4711))
+	bar, ok := c.GetInt64("bar")
+	verify.True(t, ok)
+	verify.Equal(t, bar, int64(4712))
+	_, ok = c.GetInt64("yadda")
+	verify.False(t, ok)
+}
+
 // TestClaimsFloat64 verifies the float64 operations on claims.
 func TestClaimsFloat64(t *testing.T) {
 	c := jwt.NewClaims()
@@ -0,0 +1,31 @@
+// Tideland Go JSON Web Token - Clock
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"time"
+)
+
+// Clock provides the current time for all time based operations
+// like the validation of claims or the expiration of cached tokens.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc allows to use a function as Clock.
+type ClockFunc func() time.Time
+
+// Now implements the Clock interface.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock returns the clock using the system time.
+func SystemClock() Clock {
+	return ClockFunc(time.Now)
+}
@@ -0,0 +1,149 @@
+// Tideland Go JSON Web Token - Clock - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestClockClaims verifies the validation of claims at
+// the times of a clock.
+func TestClockClaims(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	leeway := time.Second
+	c := jwt.NewClaims()
+	c.SetNotBefore(start.Add(time.Minute))
+	c.SetExpiration(start.Add(time.Hour))
+	verify.False(t, c.IsValidAt(clock.Now(), leeway))
+	clock.Advance(time.Minute - leeway)
+	verify.False(t, c.IsAlreadyValidAt(clock.Now(), leeway))
+	clock.Advance(time.Nanosecond)
+	verify.True(t, c.IsAlreadyValidAt(clock.Now(), leeway))
+	verify.True(t, c.IsValidAt(clock.Now(), leeway))
+	clock.Set(start.Add(time.Hour + leeway - time.Nanosecond))
+	verify.True(t, c.IsStillValidAt(clock.Now(), leeway))
+	clock.Advance(time.Nanosecond)
+	verify.False(t, c.IsStillValidAt(clock.Now(), leeway))
+	verify.False(t, c.IsValidAt(clock.Now(), leeway))
+}
+
+// TestClockEncode verifies the setting of the claim times
+// during encoding.
+func TestClockEncode(t *testing.T) {
+	clock := newTestClock()
+	key := []byte("secret")
+	claims := initClaims()
+	token, err := jwt.Encode(claims, key, jwt.HS512, jwt.WithClock(clock), jwt.WithLifetime(time.Hour))
+	verify.NoError(t, err)
+	iat, ok := token.Claims().IssuedAt()
+	verify.True(t, ok)
+	verify.True(t, iat.Equal(clock.Now()))
+	exp, ok := token.Claims().Expiration()
+	verify.True(t, ok)
+	verify.True(t, exp.Equal(clock.Now().Add(time.Hour)))
+	// Passed claims stay untouched.
+	verify.False(t, claims.Contains("iat"))
+	verify.False(t, claims.Contains("exp"))
+	// Time based validation during verification.
+	validator := jwt.NewValidator(jwt.ExpectValidTime(0))
+	_, err = jwt.Verify(token.String(), key, jwt.WithClock(clock), jwt.WithValidator(validator))
+	verify.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = jwt.Verify(token.String(), key, jwt.WithClock(clock), jwt.WithValidator(validator))
+	verify.ErrorMatch(t, err, ".*token expired.*")
+}
+
+// TestClockCache verifies the time based cleanup of the cache
+// without waiting.
+func TestClockCache(t *testing.T) {
+	clock := newTestClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache := jwt.NewCache(ctx, time.Minute, time.Second, time.Hour, 10, jwt.WithClock(clock))
+	key := []byte("secret")
+	// Access based cleanup.
+	jwtIn, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	_, err = cache.Put(jwtIn)
+	verify.NoError(t, err)
+	clock.Advance(30 * time.Second)
+	jwtOut, err := cache.Get(jwtIn.String())
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut, jwtIn)
+	clock.Advance(30 * time.Second)
+	verify.NoError(t, cache.Cleanup())
+	jwtOut, err = cache.Get(jwtIn.String())
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut, jwtIn)
+	clock.Advance(time.Minute)
+	verify.NoError(t, cache.Cleanup())
+	jwtOut, err = cache.Get(jwtIn.String())
+	verify.NoError(t, err)
+	verify.True(t, jwtOut == nil)
+	// Validity based removal.
+	claims := initClaims()
+	claims.SetExpiration(clock.Now().Add(10 * time.Second))
+	jwtIn, err = jwt.Encode(claims, key, jwt.HS512)
+	verify.NoError(t, err)
+	_, err = cache.Put(jwtIn)
+	verify.NoError(t, err)
+	clock.Advance(10 * time.Second)
+	jwtOut, err = cache.Get(jwtIn.String())
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut, jwtIn)
+	clock.Advance(time.Second)
+	jwtOut, err = cache.Get(jwtIn.String())
+	verify.NoError(t, err)
+	verify.True(t, jwtOut == nil)
+	// Expired tokens are not put.
+	size, err := cache.Put(jwtIn)
+	verify.NoError(t, err)
+	verify.Equal(t, size, 0)
+}
+
+// testClock is a clock for the tests which is moved manually.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newTestClock creates a test clock starting at a fixed time.
+func newTestClock() *testClock {
+	return &testClock{
+		now: time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// Now implements jwt.Clock.
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the time of the clock.
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward.
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -10,6 +10,8 @@ package jwt
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
@@ -34,6 +36,7 @@ const (
 	ES256 Algorithm = "ES256"
 	ES384 Algorithm = "ES384"
 	ES512 Algorithm = "ES512"
+	EdDSA Algorithm = "EdDSA"
 	HS256 Algorithm = "HS256"
 	HS384 Algorithm = "HS384"
 	HS512 Algorithm = "HS512"
@@ -46,44 +49,79 @@ const (
 	NONE  Algorithm = "none"
 )
 
-// ecPoint is needed to marshal R and S of the ECDSA algorithms.
+// ecPoint is needed to unmarshal R and S of ECDSA signatures in
+// the ASN.1 DER encoding of earlier releases.
 type ecPoint struct {
 	R *big.Int
 	S *big.Int
 }
 
+// legacyECDSAPublicKey marks an ECDSA public key so that the
+// verification also accepts signatures in the ASN.1 DER encoding.
+type legacyECDSAPublicKey struct {
+	*ecdsa.PublicKey
+}
+
 // Sign creates the signature for the data based on the
 // algorithm and the key.
 func (a Algorithm) Sign(data []byte, key Key) (Signature, error) {
-	switch a {
-	case ES256, HS256, PS256, RS256:
-		return a.sign(data, key, crypto.SHA256)
-	case ES384, HS384, PS384, RS384:
-		return a.sign(data, key, crypto.SHA384)
-	case ES512, HS512, PS512, RS512:
-		return a.sign(data, key, crypto.SHA512)
-	case NONE:
-		return a.sign(data, key, 0)
-	default:
-		return nil, fmt.Errorf("signing algorithm '%s' is invalid", a)
+	method, err := lookupMethod(a)
+	if err != nil {
+		return nil, err
 	}
+	return method.Sign(data, key)
 }
 
 // Verify checks if the signature is correct for the data when using
 // the passed key.
 func (a Algorithm) Verify(data []byte, sig Signature, key Key) error {
+	method, err := lookupMethod(a)
+	if err != nil {
+		return err
+	}
+	return method.Verify(data, sig, key)
+}
+
+// isECDSA returns true when the algorithm is one of
+// the ECDSA algorithms.
+func (a Algorithm) isECDSA() bool {
+	return a == ES256 || a == ES384 || a == ES512
+}
+
+// checkCurve checks if the algorithm is an ECDSA algorithm and the
+// key uses the curve bound to it by RFC 7518.
+func (a Algorithm) checkCurve(key *ecdsa.PublicKey) error {
+	curve := a.curve()
+	if curve == nil {
+		return &KeyTypeError{Algorithm: a, KeyType: "ECDSA"}
+	}
+	if key.Curve != curve {
+		return &KeyTypeError{Algorithm: a, KeyType: "ECDSA " + key.Curve.Params().Name}
+	}
+	return nil
+}
+
+// curve returns the curve bound to an ECDSA algorithm, otherwise nil.
+func (a Algorithm) curve() elliptic.Curve {
 	switch a {
-	case ES256, HS256, PS256, RS256:
-		return a.verify(data, sig, key, crypto.SHA256)
-	case ES384, HS384, PS384, RS384:
-		return a.verify(data, sig, key, crypto.SHA384)
-	case ES512, HS512, PS512, RS512:
-		return a.verify(data, sig, key, crypto.SHA512)
-	case NONE:
-		return a.verify(data, sig, key, 0)
-	default:
-		return fmt.Errorf("verifying algorithm '%s' is invalid", a)
+	case ES256:
+		return elliptic.P256()
+	case ES384:
+		return elliptic.P384()
+	case ES512:
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// keyType returns the JWK key type needed by the algorithm. It is
+// empty if all keys are accepted.
+func (a Algorithm) keyType() string {
+	method, err := lookupMethod(a)
+	if err != nil {
+		return ""
 	}
+	return method.KeyType()
 }
 
 // isRSAPSS returns true when the algorithm is one of
@@ -98,6 +136,9 @@ func (a Algorithm) sign(data []byte, k Key, h crypto.Hash) (Signature, error) {
 	case *ecdsa.PrivateKey:
 		// ECDSA algorithms.
 		return a.signECDSA(data, key, h)
+	case ed25519.PrivateKey:
+		// EdDSA algorithm.
+		return a.signEd25519(data, key)
 	case []byte:
 		// HMAC algorithms.
 		return a.signHMAC(data, key, h)
@@ -107,35 +148,106 @@ func (a Algorithm) sign(data []byte, k Key, h crypto.Hash) (Signature, error) {
 	case string:
 		// None algorithm.
 		if a != "none" {
-			return nil, fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "none")
+			return nil, &KeyTypeError{Algorithm: a, KeyType: "none"}
 		}
 		return Signature(""), nil
+	case crypto.Signer:
+		// External signers, e.g. of key management services.
+		return a.signSigner(data, key, h)
 	default:
 		// No valid key type.
-		return nil, fmt.Errorf("key type %T is invalid", k)
+		return nil, &KeyTypeError{Algorithm: a, KeyType: fmt.Sprintf("%T", k)}
+	}
+}
+
+// signSigner signs the data using a crypto.Signer. The algorithm
+// has to match the type of its public key.
+func (a Algorithm) signSigner(data []byte, signer crypto.Signer, h crypto.Hash) (Signature, error) {
+	switch public := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		if err := a.checkCurve(public); err != nil {
+			return nil, err
+		}
+		der, err := signer.Sign(rand.Reader, hashSum(data, h), h)
+		if err != nil {
+			return nil, fmt.Errorf("cannot sign the data: %w", err)
+		}
+		// Signers return ASN.1 DER, RFC 7518 demands R and S.
+		var ecp ecPoint
+		rest, err := asn1.Unmarshal(der, &ecp)
+		if err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("cannot sign the data: invalid ECDSA signature of signer")
+		}
+		size := ecKeySize(public)
+		if ecp.R.BitLen() > 8*size || ecp.S.BitLen() > 8*size {
+			return nil, fmt.Errorf("cannot sign the data: invalid ECDSA signature of signer")
+		}
+		sig := make([]byte, 2*size)
+		ecp.R.FillBytes(sig[:size])
+		ecp.S.FillBytes(sig[size:])
+		return Signature(sig), nil
+	case *rsa.PublicKey:
+		if a[0] != 'P' && a[0] != 'R' {
+			return nil, &KeyTypeError{Algorithm: a, KeyType: "RSA(PSS)"}
+		}
+		var opts crypto.SignerOpts = h
+		if a.isRSAPSS() {
+			opts = &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+				Hash:       h,
+			}
+		}
+		sig, err := signer.Sign(rand.Reader, hashSum(data, h), opts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot sign the data: %w", err)
+		}
+		return Signature(sig), nil
+	case ed25519.PublicKey:
+		if a != EdDSA {
+			return nil, &KeyTypeError{Algorithm: a, KeyType: "Ed25519"}
+		}
+		sig, err := signer.Sign(rand.Reader, data, crypto.Hash(0))
+		if err != nil {
+			return nil, fmt.Errorf("cannot sign the data: %w", err)
+		}
+		return Signature(sig), nil
+	default:
+		return nil, &KeyTypeError{Algorithm: a, KeyType: fmt.Sprintf("%T", public)}
 	}
 }
 
 // signECDSA signs the data using the ECDSA algorithm.
 func (a Algorithm) signECDSA(data []byte, key *ecdsa.PrivateKey, h crypto.Hash) (Signature, error) {
-	if a[0] != 'E' {
-		return nil, fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "ECDSA")
+	if err := a.checkCurve(&key.PublicKey); err != nil {
+		return nil, err
 	}
 	r, s, err := ecdsa.Sign(rand.Reader, key, hashSum(data, h))
 	if err != nil {
-		return nil, fmt.Errorf("cannot sign the data: %v", err)
-	}
-	sig, err := asn1.Marshal(ecPoint{r, s})
-	if err != nil {
-		return nil, fmt.Errorf("cannot sign the data: %v", err)
+		return nil, fmt.Errorf("cannot sign the data: %w", err)
 	}
+	// RFC 7518 demands the fixed length concatenation of R and S.
+	size := ecKeySize(&key.PublicKey)
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
 	return Signature(sig), nil
 }
 
+// signEd25519 signs the data using the EdDSA algorithm.
+func (a Algorithm) signEd25519(data []byte, key ed25519.PrivateKey) (Signature, error) {
+	if a != EdDSA {
+		return nil, &KeyTypeError{Algorithm: a, KeyType: "Ed25519"}
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("cannot sign the data: %w: invalid Ed25519 key size", ErrInvalidKey)
+	}
+	return Signature(ed25519.Sign(key, data)), nil
+}
+
 // signHMAC signs the data using the HMAC algorithm.
 func (a Algorithm) signHMAC(data, key []byte, h crypto.Hash) (Signature, error) {
 	if a[0] != 'H' {
-		return nil, fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "HMAC")
+		return nil, &KeyTypeError{Algorithm: a, KeyType: "HMAC"}
 	}
 	hasher := hmac.New(h.New, key)
 	if _, err := hasher.Write(data); err != nil {
@@ -148,7 +260,7 @@ func (a Algorithm) signHMAC(data, key []byte, h crypto.Hash) (Signature, error)
 // signRSA signs the data using the RSAPSS or RSA algorithm.
 func (a Algorithm) signRSA(data []byte, key *rsa.PrivateKey, h crypto.Hash) (Signature, error) {
 	if a[0] != 'P' && a[0] != 'R' {
-		return nil, fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "RSA(PSS)")
+		return nil, &KeyTypeError{Algorithm: a, KeyType: "RSA(PSS)"}
 	}
 	if a.isRSAPSS() {
 		// RSAPSS.
@@ -158,14 +270,14 @@ func (a Algorithm) signRSA(data []byte, key *rsa.PrivateKey, h crypto.Hash) (Sig
 		}
 		sig, err := rsa.SignPSS(rand.Reader, key, h, hashSum(data, h), options)
 		if err != nil {
-			return nil, fmt.Errorf("cannot sign the data: %v", err)
+			return nil, fmt.Errorf("cannot sign the data: %w", err)
 		}
 		return Signature(sig), nil
 	}
 	// RSA.
 	sig, err := rsa.SignPKCS1v15(rand.Reader, key, h, hashSum(data, h))
 	if err != nil {
-		return nil, fmt.Errorf("cannot sign the data: %v", err)
+		return nil, fmt.Errorf("cannot sign the data: %w", err)
 	}
 	return Signature(sig), nil
 }
@@ -176,7 +288,13 @@ func (a Algorithm) verify(data []byte, sig Signature, k Key, h crypto.Hash) erro
 	switch key := k.(type) {
 	case *ecdsa.PublicKey:
 		// ECDSA algorithms.
-		return a.verifyECDSA(data, sig, key, h)
+		return a.verifyECDSA(data, sig, key, h, false)
+	case legacyECDSAPublicKey:
+		// ECDSA algorithms accepting legacy signatures.
+		return a.verifyECDSA(data, sig, key.PublicKey, h, true)
+	case ed25519.PublicKey:
+		// EdDSA algorithm.
+		return a.verifyEd25519(data, sig, key)
 	case []byte:
 		// HMAC algorithms.
 		return a.verifyHMAC(data, sig, key, h)
@@ -186,29 +304,60 @@ func (a Algorithm) verify(data []byte, sig Signature, k Key, h crypto.Hash) erro
 	case string:
 		// None algorithm.
 		if a != "none" {
-			return fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "none")
+			return &KeyTypeError{Algorithm: a, KeyType: "none"}
 		}
 		if len(sig) > 0 {
-			return fmt.Errorf("data signature is invalid")
+			return ErrInvalidSignature
 		}
 		return nil
 	default:
 		// No valid key type.
-		return fmt.Errorf("key type %T is invalid", k)
+		return &KeyTypeError{Algorithm: a, KeyType: fmt.Sprintf("%T", k)}
 	}
 }
 
-// verifyECDSA verifies the data using the ECDSA algorithm.
-func (a Algorithm) verifyECDSA(data []byte, sig Signature, key *ecdsa.PublicKey, h crypto.Hash) error {
-	if a[0] != 'E' {
-		return fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "ECDSA")
+// verifyECDSA verifies the data using the ECDSA algorithm. The
+// signature has to be the concatenation of R and S, only in legacy
+// mode the ASN.1 DER encoding is accepted too.
+func (a Algorithm) verifyECDSA(data []byte, sig Signature, key *ecdsa.PublicKey, h crypto.Hash, legacy bool) error {
+	if err := a.checkCurve(key); err != nil {
+		return err
 	}
-	var ecp ecPoint
-	if _, err := asn1.Unmarshal(sig, &ecp); err != nil {
-		return fmt.Errorf("cannot verify the data: %v", err)
+	var r, s *big.Int
+	size := ecKeySize(key)
+	switch {
+	case len(sig) == 2*size:
+		r = new(big.Int).SetBytes(sig[:size])
+		s = new(big.Int).SetBytes(sig[size:])
+	case legacy:
+		var ecp ecPoint
+		rest, err := asn1.Unmarshal(sig, &ecp)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("%w: trailing signature data", ErrInvalidSignature)
+		}
+		r, s = ecp.R, ecp.S
+	default:
+		return fmt.Errorf("%w: length is %d, not %d", ErrInvalidSignature, len(sig), 2*size)
 	}
-	if !ecdsa.Verify(key, hashSum(data, h), ecp.R, ecp.S) {
-		return fmt.Errorf("data signature is invalid")
+	if !ecdsa.Verify(key, hashSum(data, h), r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyEd25519 verifies the data using the EdDSA algorithm.
+func (a Algorithm) verifyEd25519(data []byte, sig Signature, key ed25519.PublicKey) error {
+	if a != EdDSA {
+		return &KeyTypeError{Algorithm: a, KeyType: "Ed25519"}
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("cannot verify the data: %w: invalid Ed25519 key size", ErrInvalidKey)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return ErrInvalidSignature
 	}
 	return nil
 }
@@ -216,14 +365,14 @@ func (a Algorithm) verifyECDSA(data []byte, sig Signature, key *ecdsa.PublicKey,
 // verifyHMAC verifies the data using the HMAC algorithm.
 func (a Algorithm) verifyHMAC(data []byte, sig Signature, key []byte, h crypto.Hash) error {
 	if a[0] != 'H' {
-		return fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "HMAC")
+		return &KeyTypeError{Algorithm: a, KeyType: "HMAC"}
 	}
 	expectedSig, err := a.sign(data, key, h)
 	if err != nil {
-		return fmt.Errorf("cannot verify the data: %v", err)
+		return fmt.Errorf("cannot verify the data: %w", err)
 	}
 	if !hmac.Equal(sig, expectedSig) {
-		return fmt.Errorf("data signature is invalid")
+		return ErrInvalidSignature
 	}
 	return nil
 }
@@ -231,7 +380,7 @@ func (a Algorithm) verifyHMAC(data []byte, sig Signature, key []byte, h crypto.H
 // verifyRSA verifies the data using the RSAPSS or RSS algorithm.
 func (a Algorithm) verifyRSA(data []byte, sig Signature, key *rsa.PublicKey, h crypto.Hash) error {
 	if a[0] != 'P' && a[0] != 'R' {
-		return fmt.Errorf("invalid combination of algorithm '%s' and key type '%s'", a, "RSA(PSS)")
+		return &KeyTypeError{Algorithm: a, KeyType: "RSA(PSS)"}
 	}
 	if a.isRSAPSS() {
 		// RSAPSS.
@@ -240,17 +389,23 @@ func (a Algorithm) verifyRSA(data []byte, sig Signature, key *rsa.PublicKey, h c
 			Hash:       h,
 		}
 		if err := rsa.VerifyPSS(key, h, hashSum(data, h), sig, options); err != nil {
-			return fmt.Errorf("data signature is invalid: %v", err)
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
 		}
 	} else {
 		// RSA.
 		if err := rsa.VerifyPKCS1v15(key, h, hashSum(data, h), sig); err != nil {
-			return fmt.Errorf("data signature is invalid: %v", err)
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
 		}
 	}
 	return nil
 }
 
+// ecKeySize returns the size of R and S in bytes for the curve
+// of the key.
+func ecKeySize(key *ecdsa.PublicKey) int {
+	return (key.Curve.Params().BitSize + 7) / 8
+}
+
 // hashSum determines the hash sum of the passed data.
 func hashSum(data []byte, h crypto.Hash) []byte {
 	hasher := h.New()
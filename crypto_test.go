@@ -9,11 +9,14 @@ package jwt_test
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"testing"
 
@@ -32,9 +35,10 @@ var (
 
 // TestESAlgorithms verifies the ECDSA algorithms.
 func TestESAlgorithms(t *testing.T) {
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	verify.NoError(t, err)
-	for _, algo := range esTests {
+	curves := []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()}
+	for i, algo := range esTests {
+		privateKey, err := ecdsa.GenerateKey(curves[i], rand.Reader)
+		verify.NoError(t, err)
 		// Sign.
 		signature, err := algo.Sign(data, privateKey)
 		verify.NoError(t, err)
@@ -42,9 +46,94 @@ func TestESAlgorithms(t *testing.T) {
 		// Verify.
 		err = algo.Verify(data, signature, privateKey.Public())
 		verify.NoError(t, err)
+		// Keys on other curves are rejected.
+		for j, curve := range curves {
+			if j == i {
+				continue
+			}
+			otherKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+			verify.NoError(t, err)
+			_, err = algo.Sign(data, otherKey)
+			verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+			err = algo.Verify(data, signature, otherKey.Public())
+			verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+			_, err = algo.Sign(data, testSigner{otherKey})
+			verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+			// Not chosen out of key sets.
+			token, err := jwt.Encode(initClaims(), privateKey, algo)
+			verify.NoError(t, err)
+			otherJWK, err := jwt.NewJWK(&otherKey.PublicKey)
+			verify.NoError(t, err)
+			_, err = jwt.VerifyWithKeys(token.String(), jwt.NewKeySet(otherJWK))
+			verify.IsError(t, err, jwt.ErrKeyNotFound)
+		}
+	}
+}
+
+// TestESSignatureEncoding verifies the RFC 7518 encoding of the
+// ECDSA signatures as concatenation of R and S.
+func TestESSignatureEncoding(t *testing.T) {
+	tests := []struct {
+		algorithm jwt.Algorithm
+		curve     elliptic.Curve
+		length    int
+	}{
+		{jwt.ES256, elliptic.P256(), 64},
+		{jwt.ES384, elliptic.P384(), 96},
+		{jwt.ES512, elliptic.P521(), 132},
+	}
+	for _, test := range tests {
+		privateKey, err := ecdsa.GenerateKey(test.curve, rand.Reader)
+		verify.NoError(t, err)
+		signature, err := test.algorithm.Sign(data, privateKey)
+		verify.NoError(t, err)
+		verify.Length(t, signature, test.length)
+		err = test.algorithm.Verify(data, signature, privateKey.Public())
+		verify.NoError(t, err)
+		// ASN.1 DER encoded signatures are rejected.
+		derSignature, err := ecdsa.SignASN1(rand.Reader, privateKey, data)
+		verify.NoError(t, err)
+		err = test.algorithm.Verify(data, derSignature, privateKey.Public())
+		verify.ErrorMatch(t, err, ".*data signature is invalid.*")
 	}
 }
 
+// TestEdDSAAlgorithm verifies the EdDSA algorithm.
+func TestEdDSAAlgorithm(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	// Sign.
+	signature, err := jwt.EdDSA.Sign(data, privateKey)
+	verify.NoError(t, err)
+	verify.Length(t, signature, ed25519.SignatureSize)
+	// Verify.
+	err = jwt.EdDSA.Verify(data, signature, publicKey)
+	verify.NoError(t, err)
+	err = jwt.EdDSA.Verify([]byte("manipulated"), signature, publicKey)
+	verify.ErrorMatch(t, err, ".*data signature is invalid.*")
+}
+
+// TestEdDSAInterop verifies the EdDSA algorithm with the example
+// of RFC 8037 appendix A.4.
+func TestEdDSAInterop(t *testing.T) {
+	d, err := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	verify.NoError(t, err)
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	verify.NoError(t, err)
+	privateKey := ed25519.NewKeyFromSeed(d)
+	publicKey := ed25519.PublicKey(x)
+	verify.True(t, publicKey.Equal(privateKey.Public()))
+	signingInput := []byte("eyJhbGciOiJFZERTQSJ9.RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc")
+	expected := "hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+	// Sign.
+	signature, err := jwt.EdDSA.Sign(signingInput, privateKey)
+	verify.NoError(t, err)
+	verify.Equal(t, base64.RawURLEncoding.EncodeToString(signature), expected)
+	// Verify.
+	err = jwt.EdDSA.Verify(signingInput, signature, publicKey)
+	verify.NoError(t, err)
+}
+
 // TestHSAlgorithms verifies the HMAC algorithms.
 func TestHSAlgorithms(t *testing.T) {
 	key := []byte("secret")
@@ -110,6 +199,8 @@ func TestNotMatchingAlgorithm(t *testing.T) {
 	rsPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	rsPublicKey := rsPrivateKey.Public()
 	verify.NoError(t, err)
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	verify.NoError(t, err)
 	noneKey := ""
 	errorMatch := ".* combination of algorithm .* and key type .*"
 	tests := []struct {
@@ -119,16 +210,18 @@ func TestNotMatchingAlgorithm(t *testing.T) {
 		signKeys    []jwt.Key
 		verifyKeys  []jwt.Key
 	}{
-		{"ECDSA", jwt.ES512, esPrivateKey,
-			[]jwt.Key{hsKey, rsPrivateKey, noneKey}, []jwt.Key{hsKey, rsPublicKey, noneKey}},
+		{"ECDSA", jwt.ES256, esPrivateKey,
+			[]jwt.Key{edPrivateKey, hsKey, rsPrivateKey, noneKey}, []jwt.Key{edPublicKey, hsKey, rsPublicKey, noneKey}},
+		{"EdDSA", jwt.EdDSA, edPrivateKey,
+			[]jwt.Key{esPrivateKey, hsKey, rsPrivateKey, noneKey}, []jwt.Key{esPublicKey, hsKey, rsPublicKey, noneKey}},
 		{"HMAC", jwt.HS512, hsKey,
-			[]jwt.Key{esPrivateKey, rsPrivateKey, noneKey}, []jwt.Key{esPublicKey, rsPublicKey, noneKey}},
+			[]jwt.Key{esPrivateKey, edPrivateKey, rsPrivateKey, noneKey}, []jwt.Key{esPublicKey, edPublicKey, rsPublicKey, noneKey}},
 		{"RSA", jwt.RS512, rsPrivateKey,
-			[]jwt.Key{esPrivateKey, hsKey, noneKey}, []jwt.Key{esPublicKey, hsKey, noneKey}},
+			[]jwt.Key{esPrivateKey, edPrivateKey, hsKey, noneKey}, []jwt.Key{esPublicKey, edPublicKey, hsKey, noneKey}},
 		{"RSAPSS", jwt.PS512, rsPrivateKey,
-			[]jwt.Key{esPrivateKey, hsKey, noneKey}, []jwt.Key{esPublicKey, hsKey, noneKey}},
+			[]jwt.Key{esPrivateKey, edPrivateKey, hsKey, noneKey}, []jwt.Key{esPublicKey, edPublicKey, hsKey, noneKey}},
 		{"none", jwt.NONE, noneKey,
-			[]jwt.Key{esPrivateKey, hsKey, rsPrivateKey}, []jwt.Key{esPublicKey, hsKey, rsPublicKey}},
+			[]jwt.Key{esPrivateKey, edPrivateKey, hsKey, rsPrivateKey}, []jwt.Key{esPublicKey, edPublicKey, hsKey, rsPublicKey}},
 	}
 	// Run the tests.
 	for _, test := range tests {
@@ -145,6 +238,53 @@ func TestNotMatchingAlgorithm(t *testing.T) {
 	}
 }
 
+// TestSignerAlgorithms verifies the signing with crypto.Signer
+// implementations.
+func TestSignerAlgorithms(t *testing.T) {
+	esPrivateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	verify.NoError(t, err)
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	rsPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	tests := []struct {
+		algorithms []jwt.Algorithm
+		signer     crypto.Signer
+		publicKey  jwt.Key
+	}{
+		{[]jwt.Algorithm{jwt.ES384}, testSigner{esPrivateKey}, &esPrivateKey.PublicKey},
+		{[]jwt.Algorithm{jwt.EdDSA}, testSigner{edPrivateKey}, edPublicKey},
+		{psTests, testSigner{rsPrivateKey}, &rsPrivateKey.PublicKey},
+		{rsTests, testSigner{rsPrivateKey}, &rsPrivateKey.PublicKey},
+	}
+	for _, test := range tests {
+		for _, algo := range test.algorithms {
+			signature, err := algo.Sign(data, test.signer)
+			verify.NoError(t, err)
+			err = algo.Verify(data, signature, test.publicKey)
+			verify.NoError(t, err)
+			// Also as token.
+			tokenEnc, err := jwt.Encode(initClaims(), test.signer, algo)
+			verify.NoError(t, err)
+			_, err = jwt.Verify(tokenEnc.String(), test.publicKey)
+			verify.NoError(t, err)
+		}
+	}
+	// Algorithm has to match the public key.
+	for _, algo := range []jwt.Algorithm{jwt.ES256, jwt.ES512, jwt.HS256, jwt.EdDSA, jwt.RS256, jwt.NONE} {
+		_, err = algo.Sign(data, testSigner{esPrivateKey})
+		verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	}
+	for _, algo := range []jwt.Algorithm{jwt.ES256, jwt.RS256} {
+		_, err = algo.Sign(data, testSigner{edPrivateKey})
+		verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	}
+	for _, algo := range []jwt.Algorithm{jwt.ES256, jwt.EdDSA} {
+		_, err = algo.Sign(data, testSigner{rsPrivateKey})
+		verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	}
+}
+
 // TestESTools verifies the tools for the reading of PEM encoded
 func TestESTools(t *testing.T) {
 	// Generate keys and PEMs.
@@ -173,9 +313,48 @@ func TestESTools(t *testing.T) {
 	publicKeyOut, err := jwt.ReadECPublicKey(buf)
 	verify.NoError(t, err)
 	// And as a last step check if they are correctly usable.
-	signature, err := jwt.ES512.Sign(data, privateKeyOut)
+	signature, err := jwt.ES256.Sign(data, privateKeyOut)
 	verify.NoError(t, err)
-	err = jwt.ES512.Verify(data, signature, publicKeyOut)
+	err = jwt.ES256.Verify(data, signature, publicKeyOut)
+	verify.NoError(t, err)
+}
+
+// TestEdTools verifies the tools for the reading of PEM encoded
+// Ed25519 keys.
+func TestEdTools(t *testing.T) {
+	// Generate keys and PEMs.
+	publicKeyIn, privateKeyIn, err := ed25519.GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKeyIn)
+	verify.NoError(t, err)
+	privateBlock := pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateBytes,
+	}
+	privatePEM := pem.EncodeToMemory(&privateBlock)
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKeyIn)
+	verify.NoError(t, err)
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicBytes,
+	}
+	publicPEM := pem.EncodeToMemory(&publicBlock)
+	verify.NotNil(t, publicPEM)
+	// Now read them.
+	buf := bytes.NewBuffer(privatePEM)
+	privateKeyOut, err := jwt.ReadEd25519PrivateKey(buf)
+	verify.NoError(t, err)
+	buf = bytes.NewBuffer(publicPEM)
+	publicKeyOut, err := jwt.ReadEd25519PublicKey(buf)
+	verify.NoError(t, err)
+	// Reading a wrong key type fails.
+	buf = bytes.NewBuffer(publicPEM)
+	_, err = jwt.ReadECPublicKey(buf)
+	verify.ErrorMatch(t, err, ".*passed key is no ECDSA key.*")
+	// And as a last step check if they are correctly usable.
+	signature, err := jwt.EdDSA.Sign(data, privateKeyOut)
+	verify.NoError(t, err)
+	err = jwt.EdDSA.Verify(data, signature, publicKeyOut)
 	verify.NoError(t, err)
 }
 
@@ -211,3 +390,8 @@ func TestRSTools(t *testing.T) {
 	err = jwt.RS512.Verify(data, signature, publicKeyOut)
 	verify.NoError(t, err)
 }
+
+// testSigner hides the private key behind the crypto.Signer interface.
+type testSigner struct {
+	crypto.Signer
+}
@@ -0,0 +1,220 @@
+// Tideland Go JSON Web Token - ECDH Key Agreement
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// encryptKeyECDH agrees on a key with the recipient's public key
+// using an ephemeral key set as "epk" in the header. In direct mode
+// the agreed key is the content encryption key, otherwise it wraps
+// a random one.
+func (km KeyManagement) encryptKeyECDH(key Key, enc ContentEncryption, header Header) ([]byte, []byte, error) {
+	public, err := ecdhPublicKey(km, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	apu, apv, err := partyInfo(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	ephemeral, epk, err := ephemeralKey(public.Curve())
+	if err != nil {
+		return nil, nil, err
+	}
+	header.Set("epk", epk)
+	z, err := ephemeral.ECDH(public)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	if km == ECDHES {
+		return concatKDF(z, string(enc), apu, apv, enc.keySize()), nil, nil
+	}
+	kek := concatKDF(z, string(km), apu, apv, km.wrapKeySize())
+	cek, err := enc.generateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptedKey, err := wrapKey(kek, cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedKey, nil
+}
+
+// decryptKeyECDH agrees on the key with the ephemeral public key of
+// the header using the recipient's private key.
+func (km KeyManagement) decryptKeyECDH(key Key, enc ContentEncryption, header Header, encryptedKey []byte) ([]byte, error) {
+	var private *ecdh.PrivateKey
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		converted, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+		private = converted
+	case *ecdh.PrivateKey:
+		private = k
+	default:
+		return nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+	}
+	epk, err := ephemeralPublicKey(km, header)
+	if err != nil {
+		return nil, err
+	}
+	if epk.Curve() != private.Curve() {
+		return nil, fmt.Errorf("%w: curve of the ephemeral key does not match the key", ErrDecryption)
+	}
+	apu, apv, err := partyInfo(header)
+	if err != nil {
+		return nil, err
+	}
+	z, err := private.ECDH(epk)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	if km == ECDHES {
+		if len(encryptedKey) > 0 {
+			return nil, fmt.Errorf("%w: encrypted key must be empty", ErrDecryption)
+		}
+		return concatKDF(z, string(enc), apu, apv, enc.keySize()), nil
+	}
+	kek := concatKDF(z, string(km), apu, apv, km.wrapKeySize())
+	return unwrapKey(kek, encryptedKey)
+}
+
+// ecdhPublicKey returns the public key for the key agreement. EC
+// keys are converted, private keys are reduced to their public key.
+func ecdhPublicKey(km KeyManagement, key Key) (*ecdh.PublicKey, error) {
+	var public *ecdh.PublicKey
+	var err error
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		public, err = k.ECDH()
+	case *ecdsa.PrivateKey:
+		public, err = k.PublicKey.ECDH()
+	case *ecdh.PublicKey:
+		public = k
+	case *ecdh.PrivateKey:
+		public = k.PublicKey()
+	default:
+		return nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	return public, nil
+}
+
+// ephemeralKey generates an ephemeral private key on the curve and
+// returns it together with its public key as JWK.
+func ephemeralKey(curve ecdh.Curve) (*ecdh.PrivateKey, *JWK, error) {
+	var ellipticCurve elliptic.Curve
+	switch curve {
+	case ecdh.X25519():
+		private, err := curve.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot generate the ephemeral key: %w", err)
+		}
+		return private, &JWK{Key: private.PublicKey()}, nil
+	case ecdh.P256():
+		ellipticCurve = elliptic.P256()
+	case ecdh.P384():
+		ellipticCurve = elliptic.P384()
+	case ecdh.P521():
+		ellipticCurve = elliptic.P521()
+	default:
+		return nil, nil, fmt.Errorf("%w: curve '%v' is not supported", ErrInvalidKey, curve)
+	}
+	private, err := ecdsa.GenerateKey(ellipticCurve, rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot generate the ephemeral key: %w", err)
+	}
+	converted, err := private.ECDH()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot generate the ephemeral key: %w", err)
+	}
+	return converted, &JWK{Key: &private.PublicKey}, nil
+}
+
+// ephemeralPublicKey retrieves the ephemeral public key out of the
+// "epk" header parameter.
+func ephemeralPublicKey(km KeyManagement, header Header) (*ecdh.PublicKey, error) {
+	value, ok := header.Get("epk")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing header parameter 'epk'", ErrDecryption)
+	}
+	jwk, ok := value.(*JWK)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid header parameter 'epk': %w", ErrDecryption, err)
+		}
+		if jwk, err = ParseJWK(data); err != nil {
+			return nil, fmt.Errorf("%w: invalid header parameter 'epk': %w", ErrDecryption, err)
+		}
+	}
+	if jwk.IsPrivate() {
+		return nil, fmt.Errorf("%w: header parameter 'epk' contains a private key", ErrDecryption)
+	}
+	return ecdhPublicKey(km, jwk.Key)
+}
+
+// partyInfo returns the decoded "apu" and "apv" header parameters.
+func partyInfo(header Header) ([]byte, []byte, error) {
+	var infos [2][]byte
+	for i, name := range []string{"apu", "apv"} {
+		value, ok := header.Get(name)
+		if !ok {
+			continue
+		}
+		encoded, ok := value.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: header parameter '%s' is no string", ErrMalformedToken, name)
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: header parameter '%s' is invalid: %w", ErrMalformedToken, name, err)
+		}
+		infos[i] = decoded
+	}
+	return infos[0], infos[1], nil
+}
+
+// concatKDF derives a key of the given size in bytes out of the shared
+// secret using the Concat KDF with SHA-256 as defined in RFC 7518.
+func concatKDF(z []byte, algorithm string, apu, apv []byte, size int) []byte {
+	info := lengthPrefixed(nil, []byte(algorithm))
+	info = lengthPrefixed(info, apu)
+	info = lengthPrefixed(info, apv)
+	info = binary.BigEndian.AppendUint32(info, uint32(size*8))
+	var key []byte
+	for counter := uint32(1); len(key) < size; counter++ {
+		h := sha256.New()
+		h.Write(binary.BigEndian.AppendUint32(nil, counter))
+		h.Write(z)
+		h.Write(info)
+		key = h.Sum(key)
+	}
+	return key[:size]
+}
+
+// lengthPrefixed appends the data prefixed by its length.
+func lengthPrefixed(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
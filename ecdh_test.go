@@ -0,0 +1,201 @@
+// Tideland Go JSON Web Token - ECDH Key Agreement - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// keyAgreements contains all ECDH-ES key management algorithms.
+var keyAgreements = []jwt.KeyManagement{
+	jwt.ECDHES,
+	jwt.ECDHESA128KW,
+	jwt.ECDHESA192KW,
+	jwt.ECDHESA256KW,
+}
+
+// TestECDHESCurves verifies the key agreement with the NIST curves
+// using keys read from PEM.
+func TestECDHESCurves(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		recipientPrivate, recipientPublic := readECKeys(t, curve)
+		otherKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		verify.NoError(t, err)
+		for _, keyManagement := range keyAgreements {
+			jweEnc, err := jwt.Encrypt(initClaims(), recipientPublic, keyManagement, jwt.A256GCM)
+			verify.NoError(t, err)
+			encryptedKey := strings.Split(jweEnc.String(), ".")[1]
+			verify.Equal(t, encryptedKey == "", keyManagement == jwt.ECDHES)
+			epk, ok := jweEnc.Header().Get("epk")
+			verify.True(t, ok)
+			verify.NotNil(t, epk)
+			jweDec, err := jwt.Decrypt(jweEnc.String(), recipientPrivate)
+			verify.NoError(t, err)
+			verify.Equal(t, jweDec.KeyManagement(), keyManagement)
+			name, ok := jweDec.Claims().GetString("name")
+			verify.True(t, ok)
+			verify.Equal(t, name, "John Doe")
+			// Wrong keys.
+			_, err = jwt.Decrypt(jweEnc.String(), otherKey)
+			verify.IsError(t, err, jwt.ErrDecryption)
+			_, err = jwt.Decrypt(jweEnc.String(), recipientPublic)
+			verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+		}
+	}
+}
+
+// TestECDHESX25519 verifies the key agreement with X25519 keys
+// passed directly and as JWK.
+func TestECDHESX25519(t *testing.T) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	privateJWK, err := jwt.NewJWK(privateKey)
+	verify.NoError(t, err)
+	publicJWK, err := privateJWK.Public()
+	verify.NoError(t, err)
+	publicJWK.KeyID = "x25519"
+	for _, keyManagement := range keyAgreements {
+		jweEnc, err := jwt.Encrypt(initClaims(), publicJWK, keyManagement, jwt.A128GCM)
+		verify.NoError(t, err)
+		kid, ok := jweEnc.Header().KeyID()
+		verify.True(t, ok)
+		verify.Equal(t, kid, "x25519")
+		jweDec, err := jwt.Decrypt(jweEnc.String(), privateKey)
+		verify.NoError(t, err)
+		sub, ok := jweDec.Claims().Subject()
+		verify.True(t, ok)
+		verify.Equal(t, sub, "1234567890")
+		_, err = jwt.Decrypt(jweEnc.String(), privateJWK)
+		verify.NoError(t, err)
+	}
+}
+
+// TestECDHESPartyInfo verifies the usage of the "apu" and "apv"
+// header parameters.
+func TestECDHESPartyInfo(t *testing.T) {
+	recipientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	header := jwt.NewHeader()
+	header.Set("apu", base64.RawURLEncoding.EncodeToString([]byte("Alice")))
+	header.Set("apv", base64.RawURLEncoding.EncodeToString([]byte("Bob")))
+	jweEnc, err := jwt.Encrypt(initClaims(), &recipientKey.PublicKey, jwt.ECDHES, jwt.A128GCM, jwt.WithHeader(header))
+	verify.NoError(t, err)
+	jweDec, err := jwt.Decrypt(jweEnc.String(), recipientKey)
+	verify.NoError(t, err)
+	apu, ok := jweDec.Header().GetString("apu")
+	verify.True(t, ok)
+	verify.Equal(t, apu, "QWxpY2U")
+	// Invalid party info.
+	header.Set("apv", "!!!")
+	_, err = jwt.Encrypt(initClaims(), &recipientKey.PublicKey, jwt.ECDHES, jwt.A128GCM, jwt.WithHeader(header))
+	verify.ErrorContains(t, err, "header parameter 'apv' is invalid")
+	verify.IsError(t, err, jwt.ErrMalformedToken)
+}
+
+// TestECDHESInvalid verifies the rejection of invalid keys and
+// ephemeral keys.
+func TestECDHESInvalid(t *testing.T) {
+	recipientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	_, err = jwt.Encrypt(initClaims(), randomKey(t, 16), jwt.ECDHES, jwt.A128GCM)
+	verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	// Curve of the ephemeral key does not match.
+	jweEnc, err := jwt.Encrypt(initClaims(), &recipientKey.PublicKey, jwt.ECDHES, jwt.A128GCM)
+	verify.NoError(t, err)
+	otherCurveKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	verify.NoError(t, err)
+	_, err = jwt.Decrypt(jweEnc.String(), otherCurveKey)
+	verify.IsError(t, err, jwt.ErrDecryption)
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	_, err = jwt.Decrypt(jweEnc.String(), x25519Key)
+	verify.IsError(t, err, jwt.ErrDecryption)
+	// Missing ephemeral key.
+	parts := strings.Split(jweEnc.String(), ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ECDH-ES","enc":"A128GCM"}`))
+	_, err = jwt.Decrypt(strings.Join(parts, "."), recipientKey)
+	verify.IsError(t, err, jwt.ErrDecryption)
+	// Ephemeral key containing a private key.
+	privateJWK, err := jwt.NewJWK(recipientKey)
+	verify.NoError(t, err)
+	data, err := privateJWK.MarshalJSON()
+	verify.NoError(t, err)
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ECDH-ES","enc":"A128GCM","epk":` + string(data) + `}`))
+	_, err = jwt.Decrypt(strings.Join(parts, "."), recipientKey)
+	verify.IsError(t, err, jwt.ErrDecryption)
+}
+
+// TestECDHESVector verifies the key agreement with the example of
+// RFC 7518 Appendix C.
+func TestECDHESVector(t *testing.T) {
+	bob, err := jwt.ParseJWK([]byte(`{"kty":"EC","crv":"P-256",
+		"x":"weNJy2HscCSM6AEDTDg04biOvhFhyyWvOHQfeF_PxMQ",
+		"y":"e8lnCO-AlStT-NJVX-crhB7QRYhiix03illJOVAOyck",
+		"d":"VEmDZpDXXK8p8N0Cndsxs924q6nS1RXFASRl6BfUqdw"}`))
+	verify.NoError(t, err)
+	header := `{"alg":"ECDH-ES","enc":"A128GCM","apu":"QWxpY2U","apv":"Qm9i",` +
+		`"epk":{"kty":"EC","crv":"P-256",` +
+		`"x":"gI0GAILBdu7T53akrFmMyGcsF3n5dO7MmwNBHKW5SV0",` +
+		`"y":"SLW_xSffzlPWrHEVI30DHM_4egVwt3NQqeUD7nMFpps"}}`
+	headerPart := base64.RawURLEncoding.EncodeToString([]byte(header))
+	// Encrypt the claims with the derived key of the RFC.
+	cek, err := base64.RawURLEncoding.DecodeString("VqqN6vgjbSBcIijNcacQGg")
+	verify.NoError(t, err)
+	block, err := aes.NewCipher(cek)
+	verify.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	verify.NoError(t, err)
+	iv := randomKey(t, aead.NonceSize())
+	sealed := aead.Seal(nil, iv, []byte(`{"sub":"bob"}`), []byte(headerPart))
+	split := len(sealed) - aead.Overhead()
+	token := strings.Join([]string{
+		headerPart,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(sealed[:split]),
+		base64.RawURLEncoding.EncodeToString(sealed[split:]),
+	}, ".")
+	jweDec, err := jwt.Decrypt(token, bob)
+	verify.NoError(t, err)
+	sub, ok := jweDec.Claims().Subject()
+	verify.True(t, ok)
+	verify.Equal(t, sub, "bob")
+}
+
+// readECKeys creates an EC key pair on the curve and reads it
+// from PEM.
+func readECKeys(t *testing.T, curve elliptic.Curve) (jwt.Key, jwt.Key) {
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	verify.NoError(t, err)
+	privateDER, err := x509.MarshalECPrivateKey(privateKey)
+	verify.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	verify.NoError(t, err)
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	recipientPrivate, err := jwt.ReadECPrivateKey(bytes.NewReader(privatePEM))
+	verify.NoError(t, err)
+	recipientPublic, err := jwt.ReadECPublicKey(bytes.NewReader(publicPEM))
+	verify.NoError(t, err)
+	return recipientPrivate, recipientPublic
+}
@@ -0,0 +1,486 @@
+// Tideland Go JSON Web Token - Encryption Algorithms
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// minRSAKeySize is the minimum size of RSA keys for the key
+// encryption in bytes.
+const minRSAKeySize = 256
+
+// KeyManagement describes the algorithm used to determine the
+// content encryption key of an encrypted token.
+type KeyManagement string
+
+// Definition of the supported key management algorithms.
+const (
+	DIR        KeyManagement = "dir"
+	RSAOAEP    KeyManagement = "RSA-OAEP"
+	RSAOAEP256 KeyManagement = "RSA-OAEP-256"
+
+	A128KW    KeyManagement = "A128KW"
+	A192KW    KeyManagement = "A192KW"
+	A256KW    KeyManagement = "A256KW"
+	A128GCMKW KeyManagement = "A128GCMKW"
+	A192GCMKW KeyManagement = "A192GCMKW"
+	A256GCMKW KeyManagement = "A256GCMKW"
+
+	ECDHES       KeyManagement = "ECDH-ES"
+	ECDHESA128KW KeyManagement = "ECDH-ES+A128KW"
+	ECDHESA192KW KeyManagement = "ECDH-ES+A192KW"
+	ECDHESA256KW KeyManagement = "ECDH-ES+A256KW"
+)
+
+// ContentEncryption describes the algorithm used to encrypt the
+// payload of an encrypted token.
+type ContentEncryption string
+
+// Definition of the supported content encryption algorithms.
+const (
+	A128GCM ContentEncryption = "A128GCM"
+	A192GCM ContentEncryption = "A192GCM"
+	A256GCM ContentEncryption = "A256GCM"
+
+	A128CBCHS256 ContentEncryption = "A128CBC-HS256"
+	A192CBCHS384 ContentEncryption = "A192CBC-HS384"
+	A256CBCHS512 ContentEncryption = "A256CBC-HS512"
+)
+
+// encryptKey determines the content encryption key and its
+// encrypted form for the recipient's key. Parameters needed for
+// the decryption are set in the header.
+func (km KeyManagement) encryptKey(key Key, enc ContentEncryption, header Header) ([]byte, []byte, error) {
+	switch km {
+	case DIR:
+		cek, ok := key.([]byte)
+		if !ok {
+			return nil, nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+		}
+		if len(cek) != enc.keySize() {
+			return nil, nil, fmt.Errorf("%w: key size is %d, not %d", ErrInvalidKey, len(cek), enc.keySize())
+		}
+		return cek, nil, nil
+	case RSAOAEP, RSAOAEP256:
+		var public *rsa.PublicKey
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			public = k
+		case *rsa.PrivateKey:
+			public = &k.PublicKey
+		default:
+			return nil, nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+		}
+		if public.Size() < minRSAKeySize {
+			return nil, nil, fmt.Errorf("%w: RSA key size is %d bits, at least %d needed", ErrInvalidKey, public.N.BitLen(), 8*minRSAKeySize)
+		}
+		cek, err := enc.generateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		encryptedKey, err := rsa.EncryptOAEP(km.oaepHash(), rand.Reader, public, cek, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot encrypt the content encryption key: %w", err)
+		}
+		return cek, encryptedKey, nil
+	case A128KW, A192KW, A256KW:
+		kek, err := km.keyEncryptionKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		cek, err := enc.generateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		encryptedKey, err := wrapKey(kek, cek)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cek, encryptedKey, nil
+	case A128GCMKW, A192GCMKW, A256GCMKW:
+		kek, err := km.keyEncryptionKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		cek, err := enc.generateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		iv, encryptedKey, tag, err := encryptGCM(kek, cek, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("iv", base64.RawURLEncoding.EncodeToString(iv))
+		header.Set("tag", base64.RawURLEncoding.EncodeToString(tag))
+		return cek, encryptedKey, nil
+	case ECDHES, ECDHESA128KW, ECDHESA192KW, ECDHESA256KW:
+		return km.encryptKeyECDH(key, enc, header)
+	default:
+		return nil, nil, &AlgorithmError{Algorithm: Algorithm(km), Err: ErrUnsupportedAlgorithm}
+	}
+}
+
+// decryptKey determines the content encryption key out of the
+// encrypted key using the recipient's key and the header.
+func (km KeyManagement) decryptKey(key Key, enc ContentEncryption, header Header, encryptedKey []byte) ([]byte, error) {
+	switch km {
+	case DIR:
+		if len(encryptedKey) > 0 {
+			return nil, fmt.Errorf("%w: encrypted key must be empty", ErrDecryption)
+		}
+		cek, ok := key.([]byte)
+		if !ok {
+			return nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+		}
+		if len(cek) != enc.keySize() {
+			return nil, fmt.Errorf("%w: key size is %d, not %d", ErrInvalidKey, len(cek), enc.keySize())
+		}
+		return cek, nil
+	case RSAOAEP, RSAOAEP256:
+		private, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+		}
+		cek, err := rsa.DecryptOAEP(km.oaepHash(), nil, private, encryptedKey, nil)
+		if err != nil || len(cek) != enc.keySize() {
+			// Continue with a random key so that the failure only shows
+			// up when decrypting the content, see RFC 7516 section 11.5.
+			return enc.generateKey()
+		}
+		return cek, nil
+	case A128KW, A192KW, A256KW:
+		kek, err := km.keyEncryptionKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return unwrapKey(kek, encryptedKey)
+	case A128GCMKW, A192GCMKW, A256GCMKW:
+		kek, err := km.keyEncryptionKey(key)
+		if err != nil {
+			return nil, err
+		}
+		var params [2][]byte
+		for i, name := range []string{"iv", "tag"} {
+			encoded, ok := header.GetString(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: missing header parameter '%s'", ErrDecryption, name)
+			}
+			decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid header parameter '%s': %w", ErrDecryption, name, err)
+			}
+			params[i] = decoded
+		}
+		return decryptGCM(kek, params[0], encryptedKey, params[1], nil)
+	case ECDHES, ECDHESA128KW, ECDHESA192KW, ECDHESA256KW:
+		return km.decryptKeyECDH(key, enc, header, encryptedKey)
+	default:
+		return nil, &AlgorithmError{Algorithm: Algorithm(km), Err: ErrUnsupportedAlgorithm}
+	}
+}
+
+// oaepHash returns the hash used by the RSA-OAEP algorithms.
+func (km KeyManagement) oaepHash() hash.Hash {
+	if km == RSAOAEP256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// wrapKeySize returns the size of the AES key encryption key in
+// bytes, zero if the algorithm wraps no keys.
+func (km KeyManagement) wrapKeySize() int {
+	switch km {
+	case A128KW, A128GCMKW, ECDHESA128KW:
+		return 16
+	case A192KW, A192GCMKW, ECDHESA192KW:
+		return 24
+	case A256KW, A256GCMKW, ECDHESA256KW:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// keyEncryptionKey checks the passed symmetric key encryption key.
+func (km KeyManagement) keyEncryptionKey(key Key) ([]byte, error) {
+	kek, ok := key.([]byte)
+	if !ok {
+		return nil, &KeyTypeError{Algorithm: Algorithm(km), KeyType: fmt.Sprintf("%T", key)}
+	}
+	if len(kek) != km.wrapKeySize() {
+		return nil, fmt.Errorf("%w: key size is %d, not %d", ErrInvalidKey, len(kek), km.wrapKeySize())
+	}
+	return kek, nil
+}
+
+// keySize returns the size of the content encryption key in bytes,
+// zero for unsupported algorithms.
+func (enc ContentEncryption) keySize() int {
+	switch enc {
+	case A128GCM:
+		return 16
+	case A192GCM:
+		return 24
+	case A256GCM, A128CBCHS256:
+		return 32
+	case A192CBCHS384:
+		return 48
+	case A256CBCHS512:
+		return 64
+	default:
+		return 0
+	}
+}
+
+// macAlgorithm returns the HMAC algorithm and its hash used for the
+// authentication of the AES CBC algorithms.
+func (enc ContentEncryption) macAlgorithm() (Algorithm, crypto.Hash) {
+	switch enc {
+	case A128CBCHS256:
+		return HS256, crypto.SHA256
+	case A192CBCHS384:
+		return HS384, crypto.SHA384
+	default:
+		return HS512, crypto.SHA512
+	}
+}
+
+// generateKey creates a random content encryption key.
+func (enc ContentEncryption) generateKey() ([]byte, error) {
+	cek := make([]byte, enc.keySize())
+	if _, err := rand.Read(cek); err != nil {
+		return nil, fmt.Errorf("cannot generate the content encryption key: %w", err)
+	}
+	return cek, nil
+}
+
+// encrypt encrypts the plaintext with the content encryption key and
+// authenticates it together with the additional data. It returns the
+// initialization vector, the ciphertext, and the authentication tag.
+func (enc ContentEncryption) encrypt(cek, plaintext, aad []byte) ([]byte, []byte, []byte, error) {
+	switch enc {
+	case A128GCM, A192GCM, A256GCM:
+		return encryptGCM(cek, plaintext, aad)
+	case A128CBCHS256, A192CBCHS384, A256CBCHS512:
+		iv := make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			return nil, nil, nil, fmt.Errorf("cannot generate the initialization vector: %w", err)
+		}
+		ciphertext, tag, err := enc.encryptCBCHMAC(cek, iv, plaintext, aad)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return iv, ciphertext, tag, nil
+	default:
+		return nil, nil, nil, &AlgorithmError{Algorithm: Algorithm(enc), Err: ErrUnsupportedAlgorithm}
+	}
+}
+
+// decrypt checks the authentication tag and decrypts the ciphertext
+// with the content encryption key.
+func (enc ContentEncryption) decrypt(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if len(cek) != enc.keySize() {
+		return nil, fmt.Errorf("%w: content encryption key size is %d, not %d", ErrDecryption, len(cek), enc.keySize())
+	}
+	switch enc {
+	case A128GCM, A192GCM, A256GCM:
+		return decryptGCM(cek, iv, ciphertext, tag, aad)
+	case A128CBCHS256, A192CBCHS384, A256CBCHS512:
+		return enc.decryptCBCHMAC(cek, iv, ciphertext, tag, aad)
+	default:
+		return nil, &AlgorithmError{Algorithm: Algorithm(enc), Err: ErrUnsupportedAlgorithm}
+	}
+}
+
+// encryptGCM encrypts the plaintext using AES GCM with a random
+// initialization vector.
+func encryptGCM(key, plaintext, aad []byte) ([]byte, []byte, []byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot generate the initialization vector: %w", err)
+	}
+	sealed := aead.Seal(nil, iv, plaintext, aad)
+	split := len(sealed) - aead.Overhead()
+	return iv, sealed[:split], sealed[split:], nil
+}
+
+// decryptGCM decrypts the ciphertext using AES GCM.
+func decryptGCM(key, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() || len(tag) != aead.Overhead() {
+		return nil, fmt.Errorf("%w: invalid size of initialization vector or authentication tag", ErrDecryption)
+	}
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(append(sealed, ciphertext...), tag...)
+	plaintext, err := aead.Open(nil, iv, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	return plaintext, nil
+}
+
+// newGCM creates the AES GCM cipher for the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	return aead, nil
+}
+
+// encryptCBCHMAC encrypts the plaintext using AES CBC with the second
+// half of the key and authenticates it using HMAC with the first half
+// as defined in RFC 7518.
+func (enc ContentEncryption) encryptCBCHMAC(cek, iv, plaintext, aad []byte) ([]byte, []byte, error) {
+	half := len(cek) / 2
+	block, err := aes.NewCipher(cek[half:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext := make([]byte, len(plaintext)+padding)
+	copy(ciphertext, plaintext)
+	copy(ciphertext[len(plaintext):], bytes.Repeat([]byte{byte(padding)}, padding))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	tag, err := enc.authenticationTag(cek[:half], iv, ciphertext, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, tag, nil
+}
+
+// decryptCBCHMAC checks the authentication tag and decrypts the
+// ciphertext using AES CBC.
+func (enc ContentEncryption) decryptCBCHMAC(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	half := len(cek) / 2
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid size of initialization vector or ciphertext", ErrDecryption)
+	}
+	expectedTag, err := enc.authenticationTag(cek[:half], iv, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(tag, expectedTag) {
+		return nil, fmt.Errorf("%w: authentication tag is invalid", ErrDecryption)
+	}
+	block, err := aes.NewCipher(cek[half:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("%w: invalid padding", ErrDecryption)
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// authenticationTag calculates the HMAC of the additional data, the
+// initialization vector, the ciphertext, and the length of the
+// additional data. The tag is the first half of it.
+func (enc ContentEncryption) authenticationTag(macKey, iv, ciphertext, aad []byte) ([]byte, error) {
+	algorithm, h := enc.macAlgorithm()
+	data := make([]byte, 0, len(aad)+len(iv)+len(ciphertext)+8)
+	data = append(data, aad...)
+	data = append(data, iv...)
+	data = append(data, ciphertext...)
+	data = binary.BigEndian.AppendUint64(data, uint64(len(aad))*8)
+	sig, err := algorithm.signHMAC(data, macKey, h)
+	if err != nil {
+		return nil, err
+	}
+	return sig[:len(macKey)], nil
+}
+
+// keyWrapIV is the default initial value of the AES key wrap.
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// wrapKey wraps the content encryption key with the key encryption
+// key using the AES key wrap as defined in RFC 3394.
+func wrapKey(kek, cek []byte) ([]byte, error) {
+	if len(cek) < 16 || len(cek)%8 != 0 {
+		return nil, fmt.Errorf("%w: key to wrap has invalid size %d", ErrInvalidKey, len(cek))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	n := len(cek) / 8
+	wrapped := make([]byte, 8+len(cek))
+	copy(wrapped, keyWrapIV)
+	copy(wrapped[8:], cek)
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, wrapped[:8])
+			copy(b[8:], wrapped[8*i:8*i+8])
+			block.Encrypt(b, b)
+			t := binary.BigEndian.Uint64(b[:8]) ^ uint64(n*j+i)
+			binary.BigEndian.PutUint64(wrapped[:8], t)
+			copy(wrapped[8*i:], b[8:])
+		}
+	}
+	return wrapped, nil
+}
+
+// unwrapKey unwraps the content encryption key with the key
+// encryption key using the AES key wrap as defined in RFC 3394.
+func unwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("%w: wrapped key has invalid size %d", ErrDecryption, len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	n := len(wrapped)/8 - 1
+	unwrapped := make([]byte, len(wrapped))
+	copy(unwrapped, wrapped)
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := binary.BigEndian.Uint64(unwrapped[:8]) ^ uint64(n*j+i)
+			binary.BigEndian.PutUint64(b[:8], t)
+			copy(b[8:], unwrapped[8*i:8*i+8])
+			block.Decrypt(b, b)
+			copy(unwrapped[:8], b[:8])
+			copy(unwrapped[8*i:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(unwrapped[:8], keyWrapIV) != 1 {
+		return nil, fmt.Errorf("%w: integrity check of the wrapped key failed", ErrDecryption)
+	}
+	return unwrapped[8:], nil
+}
@@ -0,0 +1,248 @@
+// Tideland Go JSON Web Token - Encryption Algorithms - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestKeyWrap verifies the key management with the AES key wrap
+// and AES GCM key wrap.
+func TestKeyWrap(t *testing.T) {
+	tests := []struct {
+		keyManagement jwt.KeyManagement
+		keySize       int
+	}{
+		{jwt.A128KW, 16},
+		{jwt.A192KW, 24},
+		{jwt.A256KW, 32},
+		{jwt.A128GCMKW, 16},
+		{jwt.A192GCMKW, 24},
+		{jwt.A256GCMKW, 32},
+	}
+	for _, test := range tests {
+		key := randomKey(t, test.keySize)
+		jweEnc, err := jwt.Encrypt(initClaims(), key, test.keyManagement, jwt.A128CBCHS256)
+		verify.NoError(t, err)
+		verify.NotEmpty(t, strings.Split(jweEnc.String(), ".")[1])
+		_, hasIV := jweEnc.Header().Get("iv")
+		_, hasTag := jweEnc.Header().Get("tag")
+		isGCM := strings.HasSuffix(string(test.keyManagement), "GCMKW")
+		verify.Equal(t, hasIV, isGCM)
+		verify.Equal(t, hasTag, isGCM)
+		jweDec, err := jwt.Decrypt(jweEnc.String(), key)
+		verify.NoError(t, err)
+		verify.Equal(t, jweDec.KeyManagement(), test.keyManagement)
+		name, ok := jweDec.Claims().GetString("name")
+		verify.True(t, ok)
+		verify.Equal(t, name, "John Doe")
+		// Wrong keys.
+		_, err = jwt.Decrypt(jweEnc.String(), randomKey(t, test.keySize))
+		verify.IsError(t, err, jwt.ErrDecryption)
+		_, err = jwt.Decrypt(jweEnc.String(), randomKey(t, test.keySize+8))
+		verify.IsError(t, err, jwt.ErrInvalidKey)
+		_, err = jwt.Encrypt(initClaims(), "foo", test.keyManagement, jwt.A128GCM)
+		verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	}
+}
+
+// TestCBCHMAC verifies the content encryption with AES CBC HMAC.
+func TestCBCHMAC(t *testing.T) {
+	tests := []struct {
+		encryption jwt.ContentEncryption
+		keySize    int
+	}{
+		{jwt.A128CBCHS256, 32},
+		{jwt.A192CBCHS384, 48},
+		{jwt.A256CBCHS512, 64},
+	}
+	for _, test := range tests {
+		key := randomKey(t, test.keySize)
+		jweEnc, err := jwt.Encrypt(initClaims(), key, jwt.DIR, test.encryption)
+		verify.NoError(t, err)
+		parts := strings.Split(jweEnc.String(), ".")
+		tag, err := base64.RawURLEncoding.DecodeString(parts[4])
+		verify.NoError(t, err)
+		verify.Length(t, tag, test.keySize/2)
+		jweDec, err := jwt.Decrypt(jweEnc.String(), key)
+		verify.NoError(t, err)
+		verify.Equal(t, jweDec.ContentEncryption(), test.encryption)
+		sub, ok := jweDec.Claims().Subject()
+		verify.True(t, ok)
+		verify.Equal(t, sub, "1234567890")
+		// Tampering and wrong keys.
+		for _, i := range []int{2, 3, 4} {
+			tampered := append([]string{}, parts...)
+			tampered[i] = flipPart(t, parts[i])
+			_, err = jwt.Decrypt(strings.Join(tampered, "."), key)
+			verify.IsError(t, err, jwt.ErrDecryption)
+		}
+		_, err = jwt.Decrypt(jweEnc.String(), randomKey(t, test.keySize))
+		verify.IsError(t, err, jwt.ErrDecryption)
+	}
+}
+
+// TestKeyWrapVectors verifies the AES key wrap with the test vectors
+// of RFC 3394.
+func TestKeyWrapVectors(t *testing.T) {
+	tests := []struct {
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{
+			kek:     "000102030405060708090a0b0c0d0e0f",
+			key:     "00112233445566778899aabbccddeeff",
+			wrapped: "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+		}, {
+			kek:     "000102030405060708090a0b0c0d0e0f1011121314151617",
+			key:     "00112233445566778899aabbccddeeff",
+			wrapped: "96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d",
+		}, {
+			kek:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			key:     "00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+			wrapped: "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21",
+		},
+	}
+	for _, test := range tests {
+		kek := decodeHex(t, test.kek)
+		wrapped, err := jwt.WrapKey(kek, decodeHex(t, test.key))
+		verify.NoError(t, err)
+		verify.Equal(t, hex.EncodeToString(wrapped), test.wrapped)
+		unwrapped, err := jwt.UnwrapKey(kek, wrapped)
+		verify.NoError(t, err)
+		verify.Equal(t, hex.EncodeToString(unwrapped), test.key)
+		wrapped[0] ^= 1
+		_, err = jwt.UnwrapKey(kek, wrapped)
+		verify.IsError(t, err, jwt.ErrDecryption)
+	}
+}
+
+// TestCBCHMACVectors verifies the AES CBC HMAC encryption with the
+// test vectors of RFC 7518 Appendix B.
+func TestCBCHMACVectors(t *testing.T) {
+	plaintext := "A cipher system must not be required to be secret, and it must be able " +
+		"to fall into the hands of the enemy without inconvenience"
+	aad := "The second principle of Auguste Kerckhoffs"
+	iv := decodeHex(t, "1af38c2dc2b96ffdd86694092341bc04")
+	tests := []struct {
+		encryption jwt.ContentEncryption
+		keySize    int
+		ciphertext string
+		tag        string
+	}{
+		{
+			encryption: jwt.A128CBCHS256,
+			keySize:    32,
+			ciphertext: "c80edfa32ddf39d5ef00c0b468834279a2e46a1b8049f792f76bfe54b903a9c9" +
+				"a94ac9b47ad2655c5f10f9aef71427e2fc6f9b3f399a221489f16362c7032336" +
+				"09d45ac69864e3321cf82935ac4096c86e133314c54019e8ca7980dfa4b9cf1b" +
+				"384c486f3a54c51078158ee5d79de59fbd34d848b3d69550a67646344427ade5" +
+				"4b8851ffb598f7f80074b9473c82e2db",
+			tag: "652c3fa36b0a7c5b3219fab3a30bc1c4",
+		}, {
+			encryption: jwt.A192CBCHS384,
+			keySize:    48,
+			ciphertext: "ea65da6b59e61edb419be62d19712ae5d303eeb50052d0dfd6697f77224c8edb" +
+				"000d279bdc14c1072654bd30944230c657bed4ca0c9f4a8466f22b226d174621" +
+				"4bf8cfc2400add9f5126e479663fc90b3bed787a2f0ffcbf3904be2a641d5c21" +
+				"05bfe591bae23b1d7449e532eef60a9ac8bb6c6b01d35d49787bcd57ef484927" +
+				"f280adc91ac0c4e79c7b11efc60054e3",
+			tag: "8490ac0e58949bfe51875d733f93ac2075168039ccc733d7",
+		}, {
+			encryption: jwt.A256CBCHS512,
+			keySize:    64,
+			ciphertext: "4affaaadb78c31c5da4b1b590d10ffbd3dd8d5d302423526912da037ecbcc7bd" +
+				"822c301dd67c373bccb584ad3e9279c2e6d12a1374b77f077553df829410446b" +
+				"36ebd97066296ae6427ea75c2e0846a11a09ccf5370dc80bfecbad28c73f09b3" +
+				"a3b75e662a2594410ae496b2e2e6609e31e6e02cc837f053d21f37ff4f51950b" +
+				"be2638d09dd7a4930930806d0703b1f6",
+			tag: "4dd3b4c088a7f45c216839645b2012bf2e6269a8c56a816dbc1b267761955bc5",
+		},
+	}
+	for _, test := range tests {
+		key := make([]byte, test.keySize)
+		for i := range key {
+			key[i] = byte(i)
+		}
+		ciphertext, tag, err := jwt.EncryptCBCHMAC(test.encryption, key, iv, []byte(plaintext), []byte(aad))
+		verify.NoError(t, err)
+		verify.Equal(t, hex.EncodeToString(ciphertext), test.ciphertext)
+		verify.Equal(t, hex.EncodeToString(tag), test.tag)
+	}
+}
+
+// TestJWEVector verifies the decryption of the example token with
+// A128KW and A128CBC-HS256 of RFC 7516 Appendix A.3.
+func TestJWEVector(t *testing.T) {
+	jwk, err := jwt.ParseJWK([]byte(`{"kty":"oct","k":"GawgguFyGrWKav7AX4VKUg"}`))
+	verify.NoError(t, err)
+	token := "eyJhbGciOiJBMTI4S1ciLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0." +
+		"6KB707dM9YTIgHtLvtgWQ8mKwboJW3of9locizkDTHzBC2IlrT1oOQ." +
+		"AxY8DCtDaGlsbGljb3RoZQ." +
+		"KDlTtXchhZTGufMYmOYGS4HffxPSUrfmqCHXaI9wOGY." +
+		"U0m_YmjN04DJvceFICbCVQ"
+	payload, err := jwt.DecryptPayload(token, jwk)
+	verify.NoError(t, err)
+	verify.Equal(t, string(payload), "Live long and prosper.")
+	// The plaintext is no JSON object, so only the claims fail.
+	_, err = jwt.Decrypt(token, jwk)
+	verify.ErrorContains(t, err, "cannot decrypt the claims")
+	verify.IsError(t, err, jwt.ErrMalformedToken)
+}
+
+// TestJWERSAOAEPVector verifies the decryption of the example token
+// with RSA-OAEP and A256GCM of RFC 7516 Appendix A.1.
+func TestJWERSAOAEPVector(t *testing.T) {
+	jwk, err := jwt.ParseJWK([]byte(`{"kty":"RSA",` +
+		`"n":"oahUIoWw0K0usKNuOR6H4wkf4oBUXHTxRvgb48E-BVvxkeDNjbC4he8rUWcJoZmds2h7M70imEVhRU5djINXtqllXI4DFqcI1DgjT9LewND8` +
+		`MW2Krf3Spsk_ZkoFnilakGygTwpZ3uesH-PFABNIUYpOiN15dsQRkgr0vEhxN92i2asbOenSZeyaxziK72UwxrrKoExv6kc5twXTq4h-QChLOln0` +
+		`_mtUZwfsRaMStPs6mS6XrgxnxbWhojf663tuEQueGC-FCMfra36C9knDFGzKsNa7LZK2djYgyD3JR_MB_4NUJW_TqOQtwHYbxevoJArm-L5StowjzGy-_bq6Gw",` +
+		`"e":"AQAB",` +
+		`"d":"kLdtIj6GbDks_ApCSTYQtelcNttlKiOyPzMrXHeI-yk1F7-kpDxY4-WY5NWV5KntaEeXS1j82E375xxhWMHXyvjYecPT9fpwR_M9gV8n9Hrh` +
+		`2anTpTD93Dt62ypW3yDsJzBnTnrYu1iwWRgBKrEYY46qAZIrA2xAwnm2X7uGR1hghkqDp0Vqj3kbSCz1XyfCs6_LehBwtxHIyh8Ripy40p24moOA` +
+		`bgxVw3rxT_vlt3UVe4WO3JkJOzlpUf-KTVI2Ptgm-dARxTEtE-id-4OJr0h-K-VFs3VSndVTIznSxfyrj8ILL6MG_Uv8YAu7VILSB3lOW085-4qE3DzgrTjgyQ",` +
+		`"p":"1r52Xk46c-LsfB5P442p7atdPUrxQSy4mti_tZI3Mgf2EuFVbUoDBvaRQ-SWxkbkmoEzL7JXroSBjSrK3YIQgYdMgyAEPTPjXv_hI2_1eTSP` +
+		`VZfzL0lffNn03IXqWF5MDFuoUYE0hzb2vhrlN_rKrbfDIwUbTrjjgieRbwC6Cl0",` +
+		`"q":"wLb35x7hmQWZsWJmB_vle87ihgZ19S8lBEROLIsZG4ayZVe9Hi9gDVCOBmUDdaDYVTSNx_8Fyw1YYa9XGrGnDew00J28cRUoeBB_jKI1oma0` +
+		`Orv1T9aXIWxKwd4gvxFImOWr3QRL9KEBRzk2RatUBnmDZJTIAfwTs0g68UZHvtc",` +
+		`"dp":"ZK-YwE7diUh0qR1tR7w8WHtolDx3MZ_OTowiFvgfeQ3SiresXjm9gZ5KLhMXvo-uz-KUJWDxS5pFQ_M0evdo1dKiRTjVw_x4NyqyXPM5nULP` +
+		`kcpU827rnpZzAJKpdhWAgqrXGKAECQH0Xt4taznjnd_zVpAmZZq60WPMBMfKcuE",` +
+		`"dq":"Dq0gfgJ1DdFGXiLvQEZnuKEN0UUmsJBxkjydc3j4ZYdBiMRAy86x0vHCjywcMlYYg4yoC4YZa9hNVcsjqA3FeiL19rk8g6Qn29Tt0cj8qqyF` +
+		`pz9vNDBUfCAiJVeESOjJDZPYHdHY8v1b-o-Z2X5tvLx-TCekf7oxyeKDUqKWjis",` +
+		`"qi":"VIMpMYbPf47dT1w_zDUXfPimsSegnMOA1zTaX7aGk_8urY6R8-ZW1FxU7AlWAyLWybqq6t16VFd7hQd0y6flUK4SlOydB61gwanOsXGOAOv8` +
+		`2cHq0E3eL4HrtZkUuKvnPrMnsUUFlfUdybVzxyjz9JF_XyaY14ardLSjf4L_FNY"}`))
+	verify.NoError(t, err)
+	token := "eyJhbGciOiJSU0EtT0FFUCIsImVuYyI6IkEyNTZHQ00ifQ." +
+		"OKOawDo13gRp2ojaHV7LFpZcgV7T6DVZKTyKOMTYUmKoTCVJRgckCL9kiMT03JGeipsEdY3mx_etLbbWSrFr05kLzcSr4qKAq7YN7e9jwQRb23nf" +
+		"a6c9d-StnImGyFDbSv04uVuxIp5Zms1gNxKKK2Da14B8S4rzVRltdYwam_lDp5XnZAYpQdb76FdIKLaVmqgfwX7XWRxv2322i-vDxRfqNzo_tETKz" +
+		"pVLzfiwQyeyPGLBIO56YJ7eObdv0je81860ppamavo35UgoRdbYaBcoh9QcfylQr66oc6vFWXRcZ_ZT2LawVCWTIy3brGPi6UklfCpIMfIjf7iGdXKHzg." +
+		"48V1_ALb6US04U3b." +
+		"5eym8TW_c8SuK0ltJ3rpYIzOeDQz7TALvtu6UG9oMo4vpzs9tX_EFShS8iB7j6jiSdiwkIr3ajwQzaBtQD_A." +
+		"XFBoMYUZodetZdvTiFvSkQ"
+	payload, err := jwt.DecryptPayload(token, jwk)
+	verify.NoError(t, err)
+	verify.Equal(t, string(payload), "The true sign of intelligence is not knowledge but imagination.")
+	// Tampered tag.
+	_, err = jwt.DecryptPayload(token[:len(token)-1]+"A", jwk)
+	verify.IsError(t, err, jwt.ErrDecryption)
+}
+
+// decodeHex decodes the hex encoded string.
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	verify.NoError(t, err)
+	return b
+}
@@ -0,0 +1,112 @@
+// Tideland Go JSON Web Token - Errors
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for the failures of encoding, decoding, verification,
+// decryption, validation, and caching. They are wrapped by the returned
+// errors and can be checked with errors.Is. So e.g. ErrMalformedToken
+// and ErrMissingHeader can be mapped to a bad request, ErrInvalidSignature,
+// ErrDecryption, ErrExpired, or ErrKeyNotFound to unauthorized, and
+// ErrInvalidClaim to forbidden.
+var (
+	ErrMalformedToken        = errors.New("malformed token")
+	ErrInvalidSignature      = errors.New("data signature is invalid")
+	ErrDecryption            = errors.New("cannot decrypt the token")
+	ErrUnsupportedAlgorithm  = errors.New("unsupported algorithm")
+	ErrAlgorithmNotPermitted = errors.New("algorithm not permitted")
+	ErrKeyTypeMismatch       = errors.New("key type mismatch")
+	ErrKeyNotFound           = errors.New("key not found")
+	ErrInvalidKey            = errors.New("invalid key")
+	ErrCriticalExtension     = errors.New("critical extension not processable")
+	ErrExpired               = errors.New("token expired")
+	ErrNotYetValid           = errors.New("token not yet valid")
+	ErrMissingClaim          = errors.New("missing claim")
+	ErrInvalidClaim          = errors.New("invalid claim")
+	ErrMissingHeader         = errors.New("request contains no authorization header")
+	ErrInvalidAuthorization  = errors.New("invalid authorization header")
+	ErrCacheStopped          = errors.New("cache is stopped")
+)
+
+// AlgorithmError reports an algorithm which is not supported or
+// not permitted. Err is ErrUnsupportedAlgorithm or ErrAlgorithmNotPermitted.
+type AlgorithmError struct {
+	Algorithm Algorithm
+	Err       error
+}
+
+// Error implements the error interface.
+func (e *AlgorithmError) Error() string {
+	if e.Err == ErrAlgorithmNotPermitted {
+		return fmt.Sprintf("algorithm '%s' is not permitted", e.Algorithm)
+	}
+	return fmt.Sprintf("algorithm '%s' is not supported", e.Algorithm)
+}
+
+// Unwrap returns the sentinel error.
+func (e *AlgorithmError) Unwrap() error {
+	return e.Err
+}
+
+// KeyTypeError reports a key not matching the algorithm. It
+// matches ErrKeyTypeMismatch.
+type KeyTypeError struct {
+	Algorithm Algorithm
+	KeyType   string
+}
+
+// Error implements the error interface.
+func (e *KeyTypeError) Error() string {
+	return fmt.Sprintf("invalid combination of algorithm '%s' and key type '%s'", e.Algorithm, e.KeyType)
+}
+
+// Is allows the check for ErrKeyTypeMismatch.
+func (e *KeyTypeError) Is(target error) bool {
+	return target == ErrKeyTypeMismatch
+}
+
+// ClaimError reports a claim failing the validation. Err is one of
+// ErrMissingClaim, ErrInvalidClaim, ErrExpired, or ErrNotYetValid,
+// the reason describes the failure.
+type ClaimError struct {
+	Name   string
+	Err    error
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ClaimError) Error() string {
+	return e.Reason
+}
+
+// Unwrap returns the sentinel error.
+func (e *ClaimError) Unwrap() error {
+	return e.Err
+}
+
+// missingClaim creates the error for a missing claim.
+func missingClaim(name string) error {
+	return &ClaimError{
+		Name:   name,
+		Err:    ErrMissingClaim,
+		Reason: fmt.Sprintf("claim '%s' is missing", name),
+	}
+}
+
+// invalidClaim creates the error for a claim failing the validation.
+func invalidClaim(name string, err error, format string, args ...interface{}) error {
+	return &ClaimError{
+		Name:   name,
+		Err:    err,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
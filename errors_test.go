@@ -0,0 +1,141 @@
+// Tideland Go JSON Web Token - Errors - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestErrorsVerify verifies the sentinel and structured errors
+// returned by the verification.
+func TestErrorsVerify(t *testing.T) {
+	key := []byte("secret")
+	token, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	// Malformed tokens.
+	for _, st := range []string{"a.b", "a.b.c", "!!!." + strings.SplitN(token.String(), ".", 2)[1]} {
+		_, err = jwt.Verify(st, key)
+		verify.True(t, errors.Is(err, jwt.ErrMalformedToken))
+		_, err = jwt.Decode(st)
+		verify.True(t, errors.Is(err, jwt.ErrMalformedToken))
+	}
+	// Invalid signature.
+	_, err = jwt.Verify(token.String(), []byte("other"))
+	verify.True(t, errors.Is(err, jwt.ErrInvalidSignature))
+	// Algorithm not permitted.
+	_, err = jwt.Verify(token.String(), key, jwt.WithAlgorithms(jwt.HS256))
+	verify.True(t, errors.Is(err, jwt.ErrAlgorithmNotPermitted))
+	var algErr *jwt.AlgorithmError
+	verify.True(t, errors.As(err, &algErr))
+	verify.Equal(t, algErr.Algorithm, jwt.HS512)
+	_, err = jwt.Verify(token.String(), jwt.BindKey(key, jwt.HS256))
+	verify.True(t, errors.Is(err, jwt.ErrAlgorithmNotPermitted))
+	// Key type mismatch.
+	esKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(token.String(), &esKey.PublicKey)
+	verify.True(t, errors.Is(err, jwt.ErrKeyTypeMismatch))
+	var keyErr *jwt.KeyTypeError
+	verify.True(t, errors.As(err, &keyErr))
+	verify.Equal(t, keyErr.Algorithm, jwt.HS512)
+	verify.Equal(t, keyErr.KeyType, "ECDSA")
+	_, err = jwt.Encode(initClaims(), esKey, jwt.RS256)
+	verify.True(t, errors.Is(err, jwt.ErrKeyTypeMismatch))
+	// Unsupported algorithm.
+	_, err = jwt.Encode(initClaims(), key, jwt.Algorithm("XY256"))
+	verify.True(t, errors.Is(err, jwt.ErrUnsupportedAlgorithm))
+	// Unknown key.
+	set := jwt.NewKeySet()
+	_, err = jwt.VerifyWithKeys(token.String(), set)
+	verify.True(t, errors.Is(err, jwt.ErrKeyNotFound))
+	// Critical extensions.
+	header := jwt.NewHeader()
+	header.Set("ext", true)
+	header.SetCritical("ext")
+	token, err = jwt.Encode(initClaims(), key, jwt.HS512, jwt.WithHeader(header))
+	verify.NoError(t, err)
+	_, err = jwt.Verify(token.String(), key)
+	verify.True(t, errors.Is(err, jwt.ErrCriticalExtension))
+	// Key of a decoded token.
+	token, err = jwt.Decode(token.String())
+	verify.NoError(t, err)
+	_, err = token.Key()
+	verify.True(t, errors.Is(err, jwt.ErrKeyNotFound))
+}
+
+// TestErrorsKeys verifies the errors when parsing keys and
+// key sets.
+func TestErrorsKeys(t *testing.T) {
+	for _, data := range []string{`{`, `{"kty":"foo"}`, `{"kty":"oct"}`, `{"kty":"RSA","n":"AQAB","e":"AQAB","d":"AQAB"}`, `[]`} {
+		_, err := jwt.ParseJWK([]byte(data))
+		verify.True(t, errors.Is(err, jwt.ErrInvalidKey))
+	}
+	for _, data := range []string{`{`, `{}`, `[]`} {
+		_, err := jwt.ReadKeySet(strings.NewReader(data))
+		verify.True(t, errors.Is(err, jwt.ErrInvalidKey))
+	}
+}
+
+// TestErrorsClaims verifies the errors of the claims validation.
+func TestErrorsClaims(t *testing.T) {
+	now := time.Now()
+	key := []byte("secret")
+	claims := initClaims()
+	claims.SetIssuer("other")
+	claims.SetExpiration(now.Add(-time.Hour))
+	token, err := jwt.Encode(claims, key, jwt.HS512)
+	verify.NoError(t, err)
+	validator := jwt.NewValidator(
+		jwt.ExpectIssuer("issuer"),
+		jwt.ExpectValidTime(time.Minute),
+		jwt.RequireClaims("email"),
+	)
+	_, err = jwt.Verify(token.String(), key, jwt.WithValidator(validator))
+	verify.True(t, errors.Is(err, jwt.ErrInvalidClaim))
+	verify.True(t, errors.Is(err, jwt.ErrExpired))
+	verify.False(t, errors.Is(err, jwt.ErrNotYetValid))
+	verify.True(t, errors.Is(err, jwt.ErrMissingClaim))
+	var claimErr *jwt.ClaimError
+	verify.True(t, errors.As(err, &claimErr))
+	verify.Equal(t, claimErr.Name, "iss")
+	var validationErr *jwt.ValidationError
+	verify.True(t, errors.As(err, &validationErr))
+	verify.Length(t, validationErr.Failures, 3)
+}
+
+// TestErrorsRequest verifies the errors when retrieving tokens
+// from requests.
+func TestErrorsRequest(t *testing.T) {
+	cache := jwt.NewCache(context.Background(), time.Minute, time.Minute, time.Minute, 10)
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	verify.NoError(t, err)
+	_, err = jwt.RequestDecode(req)
+	verify.True(t, errors.Is(err, jwt.ErrMissingHeader))
+	_, err = cache.RequestDecode(req)
+	verify.True(t, errors.Is(err, jwt.ErrMissingHeader))
+	req.Header.Set("Authorization", "Basic foo")
+	_, err = jwt.RequestDecode(req)
+	verify.True(t, errors.Is(err, jwt.ErrInvalidAuthorization))
+	_, err = cache.RequestDecode(req)
+	verify.True(t, errors.Is(err, jwt.ErrInvalidAuthorization))
+	req.Header.Set("Authorization", "Bearer foo")
+	_, err = jwt.RequestVerify(req, []byte("secret"))
+	verify.True(t, errors.Is(err, jwt.ErrMalformedToken))
+}
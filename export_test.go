@@ -0,0 +1,30 @@
+// Tideland Go JSON Web Token - Exported Internals for Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+// WrapKey and UnwrapKey export the AES key wrap for the tests.
+var (
+	WrapKey   = wrapKey
+	UnwrapKey = unwrapKey
+)
+
+// EncryptCBCHMAC exports the AES CBC HMAC encryption with a fixed
+// initialization vector for the tests.
+func EncryptCBCHMAC(enc ContentEncryption, cek, iv, plaintext, aad []byte) ([]byte, []byte, error) {
+	return enc.encryptCBCHMAC(cek, iv, plaintext, aad)
+}
+
+// DecryptPayload exports the decryption of the raw payload for the
+// tests.
+func DecryptPayload(token string, key Key) ([]byte, error) {
+	jwe, err := decrypt(token, key, newOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	return jwe.payload, nil
+}
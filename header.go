@@ -0,0 +1,270 @@
+// Tideland Go JSON Web Token - Header
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// registeredHeaderParameters contains the names of the header
+// parameters registered by RFC 7515 and RFC 7516. They must not
+// be listed as critical.
+var registeredHeaderParameters = []string{
+	"alg", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256", "typ", "cty", "crit",
+	"enc", "zip", "epk", "apu", "apv", "iv", "tag", "p2s", "p2c",
+}
+
+// CriticalHandler processes the value of a header parameter listed
+// as critical. Returning an error rejects the token.
+type CriticalHandler func(value interface{}) error
+
+// Header contains the JOSE header parameters of a token. The type
+// provides getters and setters for the registered parameters, any
+// other parameters are kept as they are.
+type Header map[string]interface{}
+
+// NewHeader returns an empty set of header parameters.
+func NewHeader() Header {
+	return Header{}
+}
+
+// Get retrieves a value from the header.
+func (h Header) Get(key string) (interface{}, bool) {
+	if h == nil {
+		return nil, false
+	}
+	value, ok := h[key]
+	return value, ok
+}
+
+// GetString retrieves a string value. Other types are not converted.
+func (h Header) GetString(key string) (string, bool) {
+	value, ok := h.Get(key)
+	if !ok {
+		return "", false
+	}
+	str, ok := value.(string)
+	return str, ok
+}
+
+// GetStrings retrieves a list of strings.
+func (h Header) GetStrings(key string) ([]string, bool) {
+	value, ok := h.Get(key)
+	if !ok {
+		return nil, false
+	}
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, iv := range v {
+			str, ok := iv.(string)
+			if !ok {
+				return nil, false
+			}
+			strs[i] = str
+		}
+		return strs, true
+	}
+	return nil, false
+}
+
+// Set sets a value in the header. It returns a potential
+// old value.
+func (h Header) Set(key string, value interface{}) interface{} {
+	if h == nil {
+		return nil
+	}
+	old, _ := h.Get(key)
+	h[key] = value
+	return old
+}
+
+// Delete deletes a value from the header. It returns a potential
+// old value.
+func (h Header) Delete(key string) interface{} {
+	old, _ := h.Get(key)
+	delete(h, key)
+	return old
+}
+
+// Contains checks if the header contains a given key.
+func (h Header) Contains(key string) bool {
+	_, ok := h.Get(key)
+	return ok
+}
+
+// Algorithm retrieves the registered "alg" parameter.
+func (h Header) Algorithm() Algorithm {
+	alg, _ := h.GetString("alg")
+	return Algorithm(alg)
+}
+
+// Type retrieves the registered "typ" parameter.
+func (h Header) Type() (string, bool) {
+	return h.GetString("typ")
+}
+
+// SetType sets the registered "typ" parameter. It returns a
+// potential old value.
+func (h Header) SetType(typ string) string {
+	old, _ := h.GetString("typ")
+	h.Set("typ", typ)
+	return old
+}
+
+// ContentType retrieves the registered "cty" parameter.
+func (h Header) ContentType() (string, bool) {
+	return h.GetString("cty")
+}
+
+// SetContentType sets the registered "cty" parameter. It returns a
+// potential old value.
+func (h Header) SetContentType(cty string) string {
+	old, _ := h.GetString("cty")
+	h.Set("cty", cty)
+	return old
+}
+
+// KeyID retrieves the registered "kid" parameter.
+func (h Header) KeyID() (string, bool) {
+	return h.GetString("kid")
+}
+
+// SetKeyID sets the registered "kid" parameter. It returns a
+// potential old value.
+func (h Header) SetKeyID(kid string) string {
+	old, _ := h.GetString("kid")
+	h.Set("kid", kid)
+	return old
+}
+
+// JWKSetURL retrieves the registered "jku" parameter.
+func (h Header) JWKSetURL() (string, bool) {
+	return h.GetString("jku")
+}
+
+// SetJWKSetURL sets the registered "jku" parameter. It returns a
+// potential old value.
+func (h Header) SetJWKSetURL(jku string) string {
+	old, _ := h.GetString("jku")
+	h.Set("jku", jku)
+	return old
+}
+
+// X509CertChain retrieves the registered "x5c" parameter, the
+// BASE64 encoded DER certificates.
+func (h Header) X509CertChain() ([]string, bool) {
+	return h.GetStrings("x5c")
+}
+
+// SetX509CertChain sets the registered "x5c" parameter. It returns
+// a potential old value.
+func (h Header) SetX509CertChain(x5c ...string) []string {
+	old, _ := h.X509CertChain()
+	h.Set("x5c", x5c)
+	return old
+}
+
+// X509Thumbprint retrieves the registered "x5t" parameter.
+func (h Header) X509Thumbprint() (string, bool) {
+	return h.GetString("x5t")
+}
+
+// SetX509Thumbprint sets the registered "x5t" parameter. It returns a
+// potential old value.
+func (h Header) SetX509Thumbprint(x5t string) string {
+	old, _ := h.GetString("x5t")
+	h.Set("x5t", x5t)
+	return old
+}
+
+// X509ThumbprintS256 retrieves the registered "x5t#S256" parameter.
+func (h Header) X509ThumbprintS256() (string, bool) {
+	return h.GetString("x5t#S256")
+}
+
+// SetX509ThumbprintS256 sets the registered "x5t#S256" parameter. It returns a
+// potential old value.
+func (h Header) SetX509ThumbprintS256(x5t string) string {
+	old, _ := h.GetString("x5t#S256")
+	h.Set("x5t#S256", x5t)
+	return old
+}
+
+// Critical retrieves the registered "crit" parameter, the names of
+// the extensions which have to be understood.
+func (h Header) Critical() ([]string, bool) {
+	return h.GetStrings("crit")
+}
+
+// SetCritical sets the registered "crit" parameter. It returns a
+// potential old value.
+func (h Header) SetCritical(names ...string) []string {
+	old, _ := h.Critical()
+	h.Set("crit", names)
+	return old
+}
+
+// MarshalJSON implements the json.Marshaller interface
+// even for nil or empty headers.
+func (h Header) MarshalJSON() ([]byte, error) {
+	if len(h) == 0 {
+		return []byte("{}"), nil
+	}
+	b, err := json.Marshal(map[string]interface{}(h))
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling header to JSON: %w", err)
+	}
+	return b, nil
+}
+
+// copyHeader returns a shallow copy of the header.
+func copyHeader(h Header) Header {
+	c := make(Header, len(h)+2)
+	for key, value := range h {
+		c[key] = value
+	}
+	return c
+}
+
+// checkCritical processes the "crit" parameter as defined in
+// RFC 7515. Each listed extension has to be present and handled
+// by one of the handlers.
+func checkCritical(h Header, handlers map[string]CriticalHandler) error {
+	if !h.Contains("crit") {
+		return nil
+	}
+	names, ok := h.Critical()
+	if !ok || len(names) == 0 {
+		return fmt.Errorf("%w: critical parameter must be a non-empty list of names", ErrCriticalExtension)
+	}
+	for i, name := range names {
+		if slices.Contains(registeredHeaderParameters, name) {
+			return fmt.Errorf("%w: critical parameter must not contain registered parameter '%s'", ErrCriticalExtension, name)
+		}
+		if slices.Contains(names[:i], name) {
+			return fmt.Errorf("%w: critical parameter contains '%s' twice", ErrCriticalExtension, name)
+		}
+		handler, ok := handlers[name]
+		if !ok {
+			return fmt.Errorf("%w: critical extension '%s' is not understood", ErrCriticalExtension, name)
+		}
+		value, ok := h.Get(name)
+		if !ok {
+			return fmt.Errorf("%w: critical extension '%s' is missing", ErrCriticalExtension, name)
+		}
+		if err := handler(value); err != nil {
+			return fmt.Errorf("%w: critical extension '%s' is invalid: %w", ErrCriticalExtension, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Tideland Go JSON Web Token - Header - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestHeaderParameters verifies the setting and getting of the
+// registered header parameters.
+func TestHeaderParameters(t *testing.T) {
+	h := jwt.NewHeader()
+	verify.Equal(t, h.Algorithm(), jwt.Algorithm(""))
+	_, ok := h.KeyID()
+	verify.False(t, ok)
+	old := h.SetKeyID("foo")
+	verify.Equal(t, old, "")
+	old = h.SetKeyID("bar")
+	verify.Equal(t, old, "foo")
+	kid, ok := h.KeyID()
+	verify.True(t, ok)
+	verify.Equal(t, kid, "bar")
+	h.SetType("JWT")
+	h.SetContentType("JWT")
+	h.SetJWKSetURL("https://example.com/jwks.json")
+	h.SetX509CertChain("MIIB", "MIIC")
+	h.SetX509Thumbprint("dGh1bWI")
+	h.SetX509ThumbprintS256("c2hhMjU2")
+	h.Set("custom", 4711)
+	// Marshal and unmarshal.
+	jsonValue, err := json.Marshal(h)
+	verify.NoError(t, err)
+	var uh jwt.Header
+	err = json.Unmarshal(jsonValue, &uh)
+	verify.NoError(t, err)
+	verify.Length(t, uh, 8)
+	typ, ok := uh.Type()
+	verify.True(t, ok)
+	verify.Equal(t, typ, "JWT")
+	cty, ok := uh.ContentType()
+	verify.True(t, ok)
+	verify.Equal(t, cty, "JWT")
+	jku, ok := uh.JWKSetURL()
+	verify.True(t, ok)
+	verify.Equal(t, jku, "https://example.com/jwks.json")
+	x5c, ok := uh.X509CertChain()
+	verify.True(t, ok)
+	verify.True(t, slices.Equal(x5c, []string{"MIIB", "MIIC"}))
+	x5t, ok := uh.X509Thumbprint()
+	verify.True(t, ok)
+	verify.Equal(t, x5t, "dGh1bWI")
+	x5t, ok = uh.X509ThumbprintS256()
+	verify.True(t, ok)
+	verify.Equal(t, x5t, "c2hhMjU2")
+	custom, ok := uh.Get("custom")
+	verify.True(t, ok)
+	verify.Equal(t, custom, 4711.0)
+	// Strict types.
+	_, ok = uh.GetString("custom")
+	verify.False(t, ok)
+	verify.Equal(t, uh.Delete("custom"), interface{}(4711.0))
+	verify.False(t, uh.Contains("custom"))
+}
+
+// TestHeaderEncoding verifies the header parameters of encoded,
+// decoded, and verified tokens.
+func TestHeaderEncoding(t *testing.T) {
+	key := []byte("secret")
+	claims := initClaims()
+	// Default header.
+	tokenEnc, err := jwt.Encode(claims, key, jwt.HS256)
+	verify.NoError(t, err)
+	verify.Length(t, tokenEnc.Header(), 2)
+	verify.Equal(t, tokenEnc.Header().Algorithm(), jwt.HS256)
+	typ, _ := tokenEnc.Header().Type()
+	verify.Equal(t, typ, "JWT")
+	// Individual header, "alg" cannot be overwritten.
+	h := jwt.NewHeader()
+	h.SetKeyID("my-key")
+	h.SetType("at+jwt")
+	h.Set("alg", "none")
+	h.Set("x-custom", []string{"a", "b"})
+	tokenEnc, err = jwt.Encode(claims, key, jwt.HS256, jwt.WithHeader(h))
+	verify.NoError(t, err)
+	verify.Equal(t, tokenEnc.Header().Algorithm(), jwt.HS256)
+	verify.Equal(t, h.Algorithm(), jwt.NONE)
+	for _, decode := range []func(string) (*jwt.JWT, error){
+		jwt.Decode,
+		func(token string) (*jwt.JWT, error) { return jwt.Verify(token, key) },
+	} {
+		tokenDec, err := decode(tokenEnc.String())
+		verify.NoError(t, err)
+		header := tokenDec.Header()
+		verify.Length(t, header, 4)
+		verify.Equal(t, header.Algorithm(), jwt.HS256)
+		kid, ok := header.KeyID()
+		verify.True(t, ok)
+		verify.Equal(t, kid, "my-key")
+		typ, ok := header.Type()
+		verify.True(t, ok)
+		verify.Equal(t, typ, "at+jwt")
+		custom, ok := header.GetStrings("x-custom")
+		verify.True(t, ok)
+		verify.True(t, slices.Equal(custom, []string{"a", "b"}))
+	}
+	// Key ID of a JWK.
+	jwk, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	jwk.KeyID = "jwk-key"
+	tokenEnc, err = jwt.Encode(claims, jwk, jwt.HS256)
+	verify.NoError(t, err)
+	kid, ok := tokenEnc.Header().KeyID()
+	verify.True(t, ok)
+	verify.Equal(t, kid, "jwk-key")
+}
+
+// TestHeaderCritical verifies the processing of the "crit" header
+// parameter during verification.
+func TestHeaderCritical(t *testing.T) {
+	key := []byte("secret")
+	claims := initClaims()
+	encode := func(crit []string, params map[string]interface{}) string {
+		h := jwt.NewHeader()
+		if crit != nil {
+			h.SetCritical(crit...)
+		}
+		for name, value := range params {
+			h.Set(name, value)
+		}
+		token, err := jwt.Encode(claims, key, jwt.HS256, jwt.WithHeader(h))
+		verify.NoError(t, err)
+		return token.String()
+	}
+	var handled interface{}
+	understood := jwt.WithCritical("exp-ext", func(value interface{}) error {
+		handled = value
+		if value != "ok" {
+			return fmt.Errorf("value %v is not ok", value)
+		}
+		return nil
+	})
+	// Understood extension.
+	token := encode([]string{"exp-ext"}, map[string]interface{}{"exp-ext": "ok"})
+	_, err := jwt.Verify(token, key, understood)
+	verify.NoError(t, err)
+	verify.Equal(t, handled, interface{}("ok"))
+	// Handler rejects the value.
+	token = encode([]string{"exp-ext"}, map[string]interface{}{"exp-ext": "nok"})
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*critical extension 'exp-ext' is invalid: value nok is not ok.*")
+	// Not understood extensions.
+	token = encode([]string{"exp-ext"}, map[string]interface{}{"exp-ext": "ok"})
+	_, err = jwt.Verify(token, key)
+	verify.ErrorMatch(t, err, ".*critical extension 'exp-ext' is not understood.*")
+	token = encode([]string{"exp-ext", "other"}, map[string]interface{}{"exp-ext": "ok", "other": 1})
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*critical extension 'other' is not understood.*")
+	// Invalid lists.
+	token = encode([]string{"exp-ext"}, nil)
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*critical extension 'exp-ext' is missing.*")
+	token = encode([]string{"kid"}, map[string]interface{}{"kid": "foo"})
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*must not contain registered parameter 'kid'.*")
+	token = encode([]string{}, nil)
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*must be a non-empty list of names.*")
+	token = encode(nil, map[string]interface{}{"crit": "exp-ext", "exp-ext": "ok"})
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*must be a non-empty list of names.*")
+	token = encode([]string{"exp-ext", "exp-ext"}, map[string]interface{}{"exp-ext": "ok"})
+	_, err = jwt.Verify(token, key, understood)
+	verify.ErrorMatch(t, err, ".*contains 'exp-ext' twice.*")
+}
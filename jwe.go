@@ -0,0 +1,263 @@
+// Tideland Go JSON Web Token - JSON Web Encryption
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// JWE contains an encrypted JSON Web Token in the compact
+// serialization of RFC 7516 with its header and the decrypted
+// claims.
+type JWE struct {
+	header  Header
+	claims  Claims
+	payload []byte
+	token   string
+}
+
+// Encrypt creates an encrypted token for the given claims. The
+// content encryption key is determined by the key management
+// algorithm using the recipient's key, the claims are encrypted
+// with the content encryption algorithm. Additional header
+// parameters can be passed with WithHeader.
+func Encrypt(claims Claims, key Key, keyManagement KeyManagement, encryption ContentEncryption, options ...Option) (*JWE, error) {
+	o := newOptions(options)
+	if o.lifetime > 0 {
+		now := o.clock.Now()
+		claims = copyClaims(claims)
+		claims.SetIssuedAt(now)
+		claims.SetExpiration(now.Add(o.lifetime))
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt the claims: %w", err)
+	}
+	header := copyHeader(o.header)
+	if !header.Contains("typ") {
+		header.SetType("JWT")
+	}
+	jwe, err := encrypt(payload, header, key, keyManagement, encryption)
+	if err != nil {
+		return nil, err
+	}
+	jwe.claims = claims
+	return jwe, nil
+}
+
+// Decrypt creates a token out of a string and decrypts it with the
+// recipient's key. Tokens with extensions in the "crit" header
+// parameter are only accepted if these have been declared with
+// WithCritical, the claims are validated if a validator is passed
+// with WithValidator. The accepted algorithms can be restricted with
+// WithKeyManagement and WithContentEncryption.
+func Decrypt(token string, key Key, options ...Option) (*JWE, error) {
+	o := newOptions(options)
+	jwe, err := decrypt(token, key, o)
+	if err != nil {
+		return nil, err
+	}
+	if jwe.isNested() {
+		return nil, fmt.Errorf("cannot decrypt the claims: %w: token contains a nested JWT, use DecryptNested", ErrMalformedToken)
+	}
+	var claims Claims
+	if err = json.Unmarshal(jwe.payload, &claims); err != nil {
+		return nil, fmt.Errorf("cannot decrypt the claims: %w: %w", ErrMalformedToken, err)
+	}
+	if o.validator != nil {
+		if err = o.validator.ValidateAt(claims, o.clock.Now()); err != nil {
+			return nil, fmt.Errorf("cannot decrypt the claims: %w", err)
+		}
+	}
+	jwe.claims = claims
+	return jwe, nil
+}
+
+// EncryptNested signs the claims with the signing key and algorithm
+// like Encode and encrypts the resulting token for the recipient's
+// key. The content type of the encrypted token is set to "JWT". The
+// options are applied to the signed token.
+func EncryptNested(claims Claims, signingKey Key, algorithm Algorithm, encryptionKey Key, keyManagement KeyManagement, encryption ContentEncryption, options ...Option) (*JWE, error) {
+	jwt, err := Encode(claims, signingKey, algorithm, options...)
+	if err != nil {
+		return nil, err
+	}
+	header := NewHeader()
+	header.SetContentType("JWT")
+	jwe, err := encrypt([]byte(jwt.String()), header, encryptionKey, keyManagement, encryption)
+	if err != nil {
+		return nil, err
+	}
+	jwe.claims = jwt.Claims()
+	return jwe, nil
+}
+
+// DecryptNested decrypts a token created by EncryptNested with the
+// recipient's key and verifies the contained signed token with the
+// verification key like Verify. The options are applied to both steps.
+func DecryptNested(token string, decryptionKey, verificationKey Key, options ...Option) (*JWT, error) {
+	jwe, err := decrypt(token, decryptionKey, newOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	if !jwe.isNested() {
+		cty, _ := jwe.header.ContentType()
+		return nil, fmt.Errorf("cannot decrypt the token: %w: content type is '%s', not 'JWT'", ErrMalformedToken, cty)
+	}
+	return Verify(string(jwe.payload), verificationKey, options...)
+}
+
+// Header returns the header parameters of the token.
+func (jwe *JWE) Header() Header {
+	return jwe.header
+}
+
+// Claims returns the decrypted claims of the token.
+func (jwe *JWE) Claims() Claims {
+	return jwe.claims
+}
+
+// KeyManagement returns the key management algorithm of the token.
+func (jwe *JWE) KeyManagement() KeyManagement {
+	alg, _ := jwe.header.GetString("alg")
+	return KeyManagement(alg)
+}
+
+// ContentEncryption returns the content encryption algorithm of the token.
+func (jwe *JWE) ContentEncryption() ContentEncryption {
+	enc, _ := jwe.header.GetString("enc")
+	return ContentEncryption(enc)
+}
+
+// String implements the fmt.Stringer interface.
+func (jwe *JWE) String() string {
+	return jwe.token
+}
+
+// isNested checks if the content type marks the payload as signed
+// token.
+func (jwe *JWE) isNested() bool {
+	cty, _ := jwe.header.ContentType()
+	return strings.EqualFold(cty, "JWT")
+}
+
+// encrypt encrypts the payload for the recipient's key and creates
+// the compact serialization.
+func encrypt(payload []byte, header Header, key Key, km KeyManagement, enc ContentEncryption) (*JWE, error) {
+	if jwk, ok := key.(*JWK); ok {
+		if jwk.KeyID != "" && !header.Contains("kid") {
+			header.SetKeyID(jwk.KeyID)
+		}
+		key = jwk.Key
+	}
+	header.Set("alg", string(km))
+	header.Set("enc", string(enc))
+	if enc.keySize() == 0 {
+		return nil, fmt.Errorf("cannot encrypt the header: %w", &AlgorithmError{Algorithm: Algorithm(enc), Err: ErrUnsupportedAlgorithm})
+	}
+	cek, encryptedKey, err := km.encryptKey(key, enc, header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt the key: %w", err)
+	}
+	headerPart, err := marshallAndEncode(header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt the header: %w", err)
+	}
+	iv, ciphertext, tag, err := enc.encrypt(cek, payload, []byte(headerPart))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt the payload: %w", err)
+	}
+	token := strings.Join([]string{
+		headerPart,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, ".")
+	return &JWE{
+		header:  header,
+		payload: payload,
+		token:   token,
+	}, nil
+}
+
+// decrypt parses the compact serialization and decrypts the payload
+// with the recipient's key.
+func decrypt(token string, key Key, o *options) (*JWE, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cannot decrypt the parts: %w", ErrMalformedToken)
+	}
+	var header Header
+	if err := decodeAndUnmarshall(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("cannot decrypt the header: %w", err)
+	}
+	if err := checkCritical(header, o.critical); err != nil {
+		return nil, fmt.Errorf("cannot decrypt the header: %w", err)
+	}
+	if header.Contains("zip") {
+		return nil, fmt.Errorf("cannot decrypt the header: %w: compression is not supported", ErrUnsupportedAlgorithm)
+	}
+	alg, _ := header.GetString("alg")
+	km := KeyManagement(alg)
+	enc, _ := header.GetString("enc")
+	if ContentEncryption(enc).keySize() == 0 {
+		return nil, fmt.Errorf("cannot decrypt the header: %w", &AlgorithmError{Algorithm: Algorithm(enc), Err: ErrUnsupportedAlgorithm})
+	}
+	if err := o.permitsEncryption(km, ContentEncryption(enc)); err != nil {
+		return nil, fmt.Errorf("cannot decrypt the header: %w", err)
+	}
+	decoded := make([][]byte, 4)
+	for i, part := range parts[1:] {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt the parts: %w: %w", ErrMalformedToken, err)
+		}
+		decoded[i] = b
+	}
+	if jwk, ok := key.(*JWK); ok && !jwk.canDecrypt() {
+		return nil, fmt.Errorf("cannot decrypt the key: %w: key use is not encryption", ErrAlgorithmNotPermitted)
+	}
+	key, err := unbindKey(key, Algorithm(km))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the key: %w", err)
+	}
+	cek, err := km.decryptKey(key, ContentEncryption(enc), header, decoded[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the key: %w", err)
+	}
+	payload, err := ContentEncryption(enc).decrypt(cek, decoded[1], decoded[2], decoded[3], []byte(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the payload: %w", err)
+	}
+	return &JWE{
+		header:  header,
+		payload: payload,
+		token:   token,
+	}, nil
+}
+
+// canDecrypt checks if the JWK may be used for the decryption.
+func (jwk *JWK) canDecrypt() bool {
+	if jwk.Use != "" && jwk.Use != "enc" {
+		return false
+	}
+	if len(jwk.KeyOps) == 0 {
+		return true
+	}
+	for _, op := range []string{"decrypt", "unwrapKey", "deriveKey"} {
+		if slices.Contains(jwk.KeyOps, op) {
+			return true
+		}
+	}
+	return false
+}
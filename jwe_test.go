@@ -0,0 +1,279 @@
+// Tideland Go JSON Web Token - JSON Web Encryption - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestJWEDirect verifies the direct encryption with AES GCM.
+func TestJWEDirect(t *testing.T) {
+	tests := []struct {
+		encryption jwt.ContentEncryption
+		keySize    int
+	}{
+		{jwt.A128GCM, 16},
+		{jwt.A192GCM, 24},
+		{jwt.A256GCM, 32},
+	}
+	for _, test := range tests {
+		key := randomKey(t, test.keySize)
+		claims := initClaims()
+		jweEnc, err := jwt.Encrypt(claims, key, jwt.DIR, test.encryption)
+		verify.NoError(t, err)
+		verify.Length(t, strings.Split(jweEnc.String(), "."), 5)
+		verify.Equal(t, strings.Split(jweEnc.String(), ".")[1], "")
+		// Claims are not readable.
+		verify.False(t, strings.Contains(jweEnc.String(), base64.RawURLEncoding.EncodeToString([]byte("John Doe"))))
+		jweDec, err := jwt.Decrypt(jweEnc.String(), key)
+		verify.NoError(t, err)
+		verify.Equal(t, jweDec.KeyManagement(), jwt.DIR)
+		verify.Equal(t, jweDec.ContentEncryption(), test.encryption)
+		typ, ok := jweDec.Header().Type()
+		verify.True(t, ok)
+		verify.Equal(t, typ, "JWT")
+		name, ok := jweDec.Claims().GetString("name")
+		verify.True(t, ok)
+		verify.Equal(t, name, "John Doe")
+		// Wrong keys.
+		_, err = jwt.Decrypt(jweEnc.String(), randomKey(t, test.keySize))
+		verify.IsError(t, err, jwt.ErrDecryption)
+		_, err = jwt.Decrypt(jweEnc.String(), randomKey(t, test.keySize+8))
+		verify.IsError(t, err, jwt.ErrInvalidKey)
+		_, err = jwt.Decrypt(jweEnc.String(), "foo")
+		verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+	}
+}
+
+// TestJWERSAOAEP verifies the key encryption with RSA-OAEP.
+func TestJWERSAOAEP(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	verify.NoError(t, err)
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	recipientPrivate, err := jwt.ReadRSAPrivateKey(bytes.NewReader(privatePEM))
+	verify.NoError(t, err)
+	recipientPublic, err := jwt.ReadRSAPublicKey(bytes.NewReader(publicPEM))
+	verify.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	for _, keyManagement := range []jwt.KeyManagement{jwt.RSAOAEP, jwt.RSAOAEP256} {
+		for _, encryption := range []jwt.ContentEncryption{jwt.A128GCM, jwt.A256GCM} {
+			jweEnc, err := jwt.Encrypt(initClaims(), recipientPublic, keyManagement, encryption)
+			verify.NoError(t, err)
+			verify.NotEmpty(t, strings.Split(jweEnc.String(), ".")[1])
+			jweDec, err := jwt.Decrypt(jweEnc.String(), recipientPrivate)
+			verify.NoError(t, err)
+			verify.Equal(t, jweDec.KeyManagement(), keyManagement)
+			verify.Equal(t, jweDec.ContentEncryption(), encryption)
+			sub, ok := jweDec.Claims().Subject()
+			verify.True(t, ok)
+			verify.Equal(t, sub, "1234567890")
+			// Wrong keys, failing like a modified content.
+			_, err = jwt.Decrypt(jweEnc.String(), otherKey)
+			verify.IsError(t, err, jwt.ErrDecryption)
+			verify.ErrorMatch(t, err, "cannot decrypt the payload: .*")
+			_, err = jwt.Decrypt(jweEnc.String(), recipientPublic)
+			verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+		}
+	}
+	// Too small keys.
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	verify.NoError(t, err)
+	_, err = jwt.Encrypt(initClaims(), &smallKey.PublicKey, jwt.RSAOAEP, jwt.A128GCM)
+	verify.IsError(t, err, jwt.ErrInvalidKey)
+	_, err = jwt.Encrypt(initClaims(), []byte("secret"), jwt.RSAOAEP, jwt.A128GCM)
+	verify.IsError(t, err, jwt.ErrKeyTypeMismatch)
+}
+
+// TestJWETampering verifies the detection of modified tokens.
+func TestJWETampering(t *testing.T) {
+	key := randomKey(t, 32)
+	jweEnc, err := jwt.Encrypt(initClaims(), key, jwt.DIR, jwt.A256GCM)
+	verify.NoError(t, err)
+	parts := strings.Split(jweEnc.String(), ".")
+	// Modified header, ciphertext, and tag.
+	headerPart := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"dir","enc":"A256GCM","typ":"JOSE"}`))
+	modifications := map[int]string{
+		0: headerPart,
+		3: flipPart(t, parts[3]),
+		4: flipPart(t, parts[4]),
+	}
+	for i, part := range modifications {
+		tampered := append([]string{}, parts...)
+		tampered[i] = part
+		_, err = jwt.Decrypt(strings.Join(tampered, "."), key)
+		verify.IsError(t, err, jwt.ErrDecryption)
+	}
+	// Invalid structures.
+	_, err = jwt.Decrypt(strings.Join(parts[:4], "."), key)
+	verify.IsError(t, err, jwt.ErrMalformedToken)
+	_, err = jwt.Decrypt(parts[0]+".AA."+strings.Join(parts[2:], "."), key)
+	verify.IsError(t, err, jwt.ErrDecryption)
+	_, err = jwt.Decrypt(parts[0]+".!!."+strings.Join(parts[2:], "."), key)
+	verify.IsError(t, err, jwt.ErrMalformedToken)
+}
+
+// TestJWEOptions verifies the options of encryption and decryption.
+func TestJWEOptions(t *testing.T) {
+	clock := newTestClock()
+	key := randomKey(t, 16)
+	header := jwt.NewHeader()
+	header.SetKeyID("k1")
+	header.Set("ext", "yes")
+	header.SetCritical("ext")
+	jweEnc, err := jwt.Encrypt(initClaims(), key, jwt.DIR, jwt.A128GCM,
+		jwt.WithHeader(header), jwt.WithClock(clock), jwt.WithLifetime(time.Minute))
+	verify.NoError(t, err)
+	exp, ok := jweEnc.Claims().Expiration()
+	verify.True(t, ok)
+	verify.Equal(t, exp.Unix(), clock.Now().Add(time.Minute).Unix())
+	// Critical extensions.
+	_, err = jwt.Decrypt(jweEnc.String(), key)
+	verify.IsError(t, err, jwt.ErrCriticalExtension)
+	critical := jwt.WithCritical("ext", func(value interface{}) error { return nil })
+	jweDec, err := jwt.Decrypt(jweEnc.String(), key, critical)
+	verify.NoError(t, err)
+	kid, ok := jweDec.Header().KeyID()
+	verify.True(t, ok)
+	verify.Equal(t, kid, "k1")
+	// Validation.
+	validator := jwt.NewValidator(jwt.ExpectValidTime(0))
+	_, err = jwt.Decrypt(jweEnc.String(), key, critical, jwt.WithValidator(validator), jwt.WithClock(clock))
+	verify.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = jwt.Decrypt(jweEnc.String(), key, critical, jwt.WithValidator(validator), jwt.WithClock(clock))
+	verify.IsError(t, err, jwt.ErrExpired)
+	// Unsupported algorithms.
+	_, err = jwt.Encrypt(initClaims(), key, jwt.DIR, jwt.ContentEncryption("A128XYZ"))
+	verify.IsError(t, err, jwt.ErrUnsupportedAlgorithm)
+	_, err = jwt.Encrypt(initClaims(), key, jwt.KeyManagement("XYZ"), jwt.A128GCM)
+	verify.IsError(t, err, jwt.ErrUnsupportedAlgorithm)
+	// JWK with key ID.
+	jwk, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	jwk.KeyID = "k2"
+	jweEnc, err = jwt.Encrypt(initClaims(), jwk, jwt.DIR, jwt.A128GCM)
+	verify.NoError(t, err)
+	jweDec, err = jwt.Decrypt(jweEnc.String(), jwk)
+	verify.NoError(t, err)
+	kid, ok = jweDec.Header().KeyID()
+	verify.True(t, ok)
+	verify.Equal(t, kid, "k2")
+}
+
+// TestJWEAlgorithms verifies the restriction of the accepted
+// algorithms by options and by the JWK.
+func TestJWEAlgorithms(t *testing.T) {
+	key := randomKey(t, 16)
+	jweEnc, err := jwt.Encrypt(initClaims(), key, jwt.A128KW, jwt.A128GCM)
+	verify.NoError(t, err)
+	st := jweEnc.String()
+	_, err = jwt.Decrypt(st, key, jwt.WithKeyManagement(jwt.A128KW), jwt.WithContentEncryption(jwt.A128GCM, jwt.A256GCM))
+	verify.NoError(t, err)
+	_, err = jwt.Decrypt(st, key, jwt.WithKeyManagement(jwt.RSAOAEP256))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = jwt.Decrypt(st, key, jwt.WithContentEncryption(jwt.A256GCM))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = jwt.DecryptNested(st, key, key, jwt.WithKeyManagement(jwt.DIR))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	// Algorithm, use, and operations of the JWK.
+	jwk, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	jwk.Algorithm = jwt.Algorithm(jwt.A128KW)
+	jwk.Use = "enc"
+	jwk.KeyOps = []string{"wrapKey", "unwrapKey"}
+	_, err = jwt.Decrypt(st, jwk)
+	verify.NoError(t, err)
+	jwk.Algorithm = jwt.Algorithm(jwt.A128GCMKW)
+	_, err = jwt.Decrypt(st, jwk)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	jwk.Algorithm = ""
+	jwk.Use = "sig"
+	_, err = jwt.Decrypt(st, jwk)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	jwk.Use = ""
+	jwk.KeyOps = []string{"wrapKey"}
+	_, err = jwt.Decrypt(st, jwk)
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = jwt.Decrypt(st, jwt.BindKey(key, jwt.Algorithm(jwt.A256KW)))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+}
+
+// TestJWENested verifies signing and encrypting of nested tokens.
+func TestJWENested(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	encryptionKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	verify.NoError(t, err)
+	jweEnc, err := jwt.EncryptNested(initClaims(), signingKey, jwt.ES256, &encryptionKey.PublicKey, jwt.ECDHESA256KW, jwt.A256GCM,
+		jwt.WithLifetime(time.Hour))
+	verify.NoError(t, err)
+	cty, ok := jweEnc.Header().ContentType()
+	verify.True(t, ok)
+	verify.Equal(t, cty, "JWT")
+	_, ok = jweEnc.Claims().Expiration()
+	verify.True(t, ok)
+	jwtDec, err := jwt.DecryptNested(jweEnc.String(), encryptionKey, &signingKey.PublicKey,
+		jwt.WithAlgorithms(jwt.ES256),
+		jwt.WithValidator(jwt.NewValidator(jwt.ExpectSubject("1234567890"))))
+	verify.NoError(t, err)
+	verify.Equal(t, jwtDec.Algorithm(), jwt.ES256)
+	name, ok := jwtDec.Claims().GetString("name")
+	verify.True(t, ok)
+	verify.Equal(t, name, "John Doe")
+	// Wrong keys and options.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	_, err = jwt.DecryptNested(jweEnc.String(), encryptionKey, &otherKey.PublicKey)
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	_, err = jwt.DecryptNested(jweEnc.String(), encryptionKey, &signingKey.PublicKey, jwt.WithAlgorithms(jwt.ES384))
+	verify.IsError(t, err, jwt.ErrAlgorithmNotPermitted)
+	_, err = jwt.DecryptNested(jweEnc.String(), otherKey, &signingKey.PublicKey)
+	verify.IsError(t, err, jwt.ErrDecryption)
+	// Mixing up nested and not nested tokens.
+	_, err = jwt.Decrypt(jweEnc.String(), encryptionKey)
+	verify.ErrorContains(t, err, "use DecryptNested")
+	key := randomKey(t, 16)
+	jweEnc, err = jwt.Encrypt(initClaims(), key, jwt.DIR, jwt.A128GCM)
+	verify.NoError(t, err)
+	_, err = jwt.DecryptNested(jweEnc.String(), key, &signingKey.PublicKey)
+	verify.IsError(t, err, jwt.ErrMalformedToken)
+}
+
+// randomKey creates a random symmetric key.
+func randomKey(t *testing.T, size int) []byte {
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	verify.NoError(t, err)
+	return key
+}
+
+// flipPart flips the first bit of a BASE64 encoded token part.
+func flipPart(t *testing.T, part string) string {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	verify.NoError(t, err)
+	b[0] ^= 1
+	return base64.RawURLEncoding.EncodeToString(b)
+}
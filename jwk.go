@@ -0,0 +1,564 @@
+// Tideland Go JSON Web Token - JSON Web Key
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Key types of JSON Web Keys.
+const (
+	KeyTypeEC  = "EC"
+	KeyTypeRSA = "RSA"
+	KeyTypeOct = "oct"
+	KeyTypeOKP = "OKP"
+)
+
+// JWK is a JSON Web Key as defined in RFC 7517. It contains one of
+// the key types accepted by the algorithms together with the
+// describing members of the JWK.
+type JWK struct {
+	Key       Key
+	KeyID     string
+	Use       string
+	KeyOps    []string
+	Algorithm Algorithm
+}
+
+// jwkJSON contains the JSON members of a JWK.
+type jwkJSON struct {
+	KeyType   string   `json:"kty"`
+	KeyID     string   `json:"kid,omitempty"`
+	Use       string   `json:"use,omitempty"`
+	KeyOps    []string `json:"key_ops,omitempty"`
+	Algorithm string   `json:"alg,omitempty"`
+	Curve     string   `json:"crv,omitempty"`
+	X         string   `json:"x,omitempty"`
+	Y         string   `json:"y,omitempty"`
+	N         string   `json:"n,omitempty"`
+	E         string   `json:"e,omitempty"`
+	D         string   `json:"d,omitempty"`
+	P         string   `json:"p,omitempty"`
+	Q         string   `json:"q,omitempty"`
+	DP        string   `json:"dp,omitempty"`
+	DQ        string   `json:"dq,omitempty"`
+	QI        string   `json:"qi,omitempty"`
+	K         string   `json:"k,omitempty"`
+}
+
+// NewJWK creates a JSON Web Key for the passed key. In case of a
+// BoundKey its algorithm is taken over.
+func NewJWK(key Key) (*JWK, error) {
+	jwk := &JWK{}
+	if bk, ok := key.(*BoundKey); ok {
+		key = bk.Key
+		jwk.Algorithm = bk.Algorithm
+	}
+	jwk.Key = key
+	if _, err := jwk.keyType(); err != nil {
+		return nil, err
+	}
+	return jwk, nil
+}
+
+// ParseJWK parses the passed JSON encoded JSON Web Key.
+func ParseJWK(data []byte) (*JWK, error) {
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		if !errors.Is(err, ErrInvalidKey) {
+			// Syntax errors are returned before UnmarshalJSON.
+			err = fmt.Errorf("cannot unmarshal the JWK: %w: %w", ErrInvalidKey, err)
+		}
+		return nil, err
+	}
+	return &jwk, nil
+}
+
+// ReadJWK reads a JSON encoded JSON Web Key from the passed reader.
+func ReadJWK(r io.Reader) (*JWK, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the JWK: %w", err)
+	}
+	return ParseJWK(data)
+}
+
+// KeyType returns the "kty" of the key.
+func (jwk *JWK) KeyType() string {
+	kty, _ := jwk.keyType()
+	return kty
+}
+
+// IsPrivate returns true if the JWK contains a private or
+// a symmetric key.
+func (jwk *JWK) IsPrivate() bool {
+	switch jwk.Key.(type) {
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey, *ecdh.PrivateKey, []byte:
+		return true
+	}
+	return false
+}
+
+// Public returns a JWK containing only the public part of the key
+// and the same describing members. Symmetric keys have no public
+// part and lead to an error.
+func (jwk *JWK) Public() (*JWK, error) {
+	var public Key
+	switch key := jwk.Key.(type) {
+	case *ecdsa.PrivateKey:
+		public = &key.PublicKey
+	case *rsa.PrivateKey:
+		public = &key.PublicKey
+	case ed25519.PrivateKey:
+		public = key.Public()
+	case *ecdh.PrivateKey:
+		public = key.PublicKey()
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey, *ecdh.PublicKey:
+		public = key
+	default:
+		return nil, fmt.Errorf("%w: key type %T has no public key", ErrInvalidKey, jwk.Key)
+	}
+	return &JWK{
+		Key:       public,
+		KeyID:     jwk.KeyID,
+		Use:       jwk.Use,
+		KeyOps:    append([]string(nil), jwk.KeyOps...),
+		Algorithm: jwk.Algorithm,
+	}, nil
+}
+
+// Thumbprint computes the JWK thumbprint as defined in RFC 7638 with
+// the passed hash. Only the required members of the public key are
+// used, so a private key and its public key have the same thumbprint.
+func (jwk *JWK) Thumbprint(h crypto.Hash) ([]byte, error) {
+	if !h.Available() {
+		return nil, fmt.Errorf("%w: hash %v is not available", ErrUnsupportedAlgorithm, h)
+	}
+	raw, err := jwk.members()
+	if err != nil {
+		return nil, err
+	}
+	required := map[string]string{
+		"kty": raw.KeyType,
+	}
+	switch raw.KeyType {
+	case KeyTypeEC:
+		required["crv"] = raw.Curve
+		required["x"] = raw.X
+		required["y"] = raw.Y
+	case KeyTypeRSA:
+		required["e"] = raw.E
+		required["n"] = raw.N
+	case KeyTypeOKP:
+		required["crv"] = raw.Curve
+		required["x"] = raw.X
+	case KeyTypeOct:
+		required["k"] = raw.K
+	}
+	// Maps are marshalled with lexicographically sorted members.
+	data, err := json.Marshal(required)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal the JWK members: %w", err)
+	}
+	return hashSum(data, h), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (jwk *JWK) MarshalJSON() ([]byte, error) {
+	raw, err := jwk.members()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// members creates the JSON members of the JWK.
+func (jwk *JWK) members() (*jwkJSON, error) {
+	raw := jwkJSON{
+		KeyID:     jwk.KeyID,
+		Use:       jwk.Use,
+		KeyOps:    jwk.KeyOps,
+		Algorithm: string(jwk.Algorithm),
+	}
+	switch key := jwk.Key.(type) {
+	case *ecdsa.PrivateKey:
+		if err := raw.setECPublicKey(&key.PublicKey); err != nil {
+			return nil, err
+		}
+		raw.D = encodeFixedInt(key.D, ecKeySize(&key.PublicKey))
+	case *ecdsa.PublicKey:
+		if err := raw.setECPublicKey(key); err != nil {
+			return nil, err
+		}
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return nil, fmt.Errorf("%w: cannot marshal RSA key with %d primes", ErrInvalidKey, len(key.Primes))
+		}
+		p, q := key.Primes[0], key.Primes[1]
+		one := big.NewInt(1)
+		raw.setRSAPublicKey(&key.PublicKey)
+		raw.D = encodeInt(key.D)
+		raw.P = encodeInt(p)
+		raw.Q = encodeInt(q)
+		raw.DP = encodeInt(new(big.Int).Mod(key.D, new(big.Int).Sub(p, one)))
+		raw.DQ = encodeInt(new(big.Int).Mod(key.D, new(big.Int).Sub(q, one)))
+		raw.QI = encodeInt(new(big.Int).ModInverse(q, p))
+	case *rsa.PublicKey:
+		raw.setRSAPublicKey(key)
+	case ed25519.PrivateKey:
+		raw.KeyType = KeyTypeOKP
+		raw.Curve = "Ed25519"
+		raw.X = encodeBytes(key.Public().(ed25519.PublicKey))
+		raw.D = encodeBytes(key.Seed())
+	case ed25519.PublicKey:
+		raw.KeyType = KeyTypeOKP
+		raw.Curve = "Ed25519"
+		raw.X = encodeBytes(key)
+	case *ecdh.PrivateKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("%w: only X25519 is supported for ECDH keys", ErrInvalidKey)
+		}
+		raw.KeyType = KeyTypeOKP
+		raw.Curve = "X25519"
+		raw.X = encodeBytes(key.PublicKey().Bytes())
+		raw.D = encodeBytes(key.Bytes())
+	case *ecdh.PublicKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("%w: only X25519 is supported for ECDH keys", ErrInvalidKey)
+		}
+		raw.KeyType = KeyTypeOKP
+		raw.Curve = "X25519"
+		raw.X = encodeBytes(key.Bytes())
+	case []byte:
+		raw.KeyType = KeyTypeOct
+		raw.K = encodeBytes(key)
+	default:
+		return nil, fmt.Errorf("%w: key type %T is invalid", ErrInvalidKey, jwk.Key)
+	}
+	return &raw, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (jwk *JWK) UnmarshalJSON(data []byte) error {
+	var raw jwkJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("cannot unmarshal the JWK: %w: %w", ErrInvalidKey, err)
+	}
+	var key Key
+	var err error
+	switch raw.KeyType {
+	case KeyTypeEC:
+		key, err = raw.ecKey()
+	case KeyTypeRSA:
+		key, err = raw.rsaKey()
+	case KeyTypeOct:
+		key, err = decodeBytes("k", raw.K)
+	case KeyTypeOKP:
+		key, err = raw.okpKey()
+	case "":
+		err = fmt.Errorf("missing key type")
+	default:
+		err = fmt.Errorf("key type '%s' is not supported", raw.KeyType)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal the JWK: %w: %w", ErrInvalidKey, err)
+	}
+	*jwk = JWK{
+		Key:       key,
+		KeyID:     raw.KeyID,
+		Use:       raw.Use,
+		KeyOps:    raw.KeyOps,
+		Algorithm: Algorithm(raw.Algorithm),
+	}
+	return nil
+}
+
+// keyType determines the "kty" of the contained key.
+func (jwk *JWK) keyType() (string, error) {
+	switch key := jwk.Key.(type) {
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return KeyTypeEC, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return KeyTypeRSA, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return KeyTypeOKP, nil
+	case *ecdh.PrivateKey:
+		if key.Curve() == ecdh.X25519() {
+			return KeyTypeOKP, nil
+		}
+	case *ecdh.PublicKey:
+		if key.Curve() == ecdh.X25519() {
+			return KeyTypeOKP, nil
+		}
+	case []byte:
+		return KeyTypeOct, nil
+	}
+	return "", fmt.Errorf("%w: key type %T is invalid", ErrInvalidKey, jwk.Key)
+}
+
+// setECPublicKey sets the members of an ECDSA public key.
+func (raw *jwkJSON) setECPublicKey(key *ecdsa.PublicKey) error {
+	crv, err := curveName(key.Curve)
+	if err != nil {
+		return err
+	}
+	size := ecKeySize(key)
+	raw.KeyType = KeyTypeEC
+	raw.Curve = crv
+	raw.X = encodeFixedInt(key.X, size)
+	raw.Y = encodeFixedInt(key.Y, size)
+	return nil
+}
+
+// setRSAPublicKey sets the members of an RSA public key.
+func (raw *jwkJSON) setRSAPublicKey(key *rsa.PublicKey) {
+	raw.KeyType = KeyTypeRSA
+	raw.N = encodeInt(key.N)
+	raw.E = encodeInt(big.NewInt(int64(key.E)))
+}
+
+// ecKey creates the ECDSA key out of the members.
+func (raw *jwkJSON) ecKey() (Key, error) {
+	curve, ecdhCurve, err := curveByName(raw.Curve)
+	if err != nil {
+		return nil, err
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := decodeFixedBytes("x", raw.X, size)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeFixedBytes("y", raw.Y, size)
+	if err != nil {
+		return nil, err
+	}
+	// Let crypto/ecdh validate that the point is on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid EC public key: %w", err)
+	}
+	publicKey := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if raw.D == "" {
+		return publicKey, nil
+	}
+	d, err := decodeFixedBytes("d", raw.D, size)
+	if err != nil {
+		return nil, err
+	}
+	ecdhKey, err := ecdhCurve.NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC private key: %w", err)
+	}
+	if !bytes.Equal(ecdhKey.PublicKey().Bytes(), point) {
+		return nil, fmt.Errorf("EC private key does not match the public key")
+	}
+	return &ecdsa.PrivateKey{
+		PublicKey: *publicKey,
+		D:         new(big.Int).SetBytes(d),
+	}, nil
+}
+
+// rsaKey creates the RSA key out of the members.
+func (raw *jwkJSON) rsaKey() (Key, error) {
+	n, err := decodeInt("n", raw.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeInt("e", raw.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA public exponent")
+	}
+	publicKey := &rsa.PublicKey{
+		N: n,
+		E: int(e.Int64()),
+	}
+	if raw.D == "" {
+		return publicKey, nil
+	}
+	if raw.P == "" || raw.Q == "" {
+		return nil, fmt.Errorf("RSA private key needs the primes 'p' and 'q'")
+	}
+	d, err := decodeInt("d", raw.D)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeInt("p", raw.P)
+	if err != nil {
+		return nil, err
+	}
+	q, err := decodeInt("q", raw.Q)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := &rsa.PrivateKey{
+		PublicKey: *publicKey,
+		D:         d,
+		Primes:    []*big.Int{p, q},
+	}
+	if err := privateKey.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RSA private key: %w", err)
+	}
+	privateKey.Precompute()
+	return privateKey, nil
+}
+
+// okpKey creates the octet key pair out of the members.
+func (raw *jwkJSON) okpKey() (Key, error) {
+	switch raw.Curve {
+	case "Ed25519":
+		return raw.ed25519Key()
+	case "X25519":
+		return raw.x25519Key()
+	default:
+		return nil, fmt.Errorf("curve '%s' is not supported", raw.Curve)
+	}
+}
+
+// ed25519Key creates the Ed25519 key out of the members.
+func (raw *jwkJSON) ed25519Key() (Key, error) {
+	x, err := decodeFixedBytes("x", raw.X, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := ed25519.PublicKey(x)
+	if raw.D == "" {
+		return publicKey, nil
+	}
+	d, err := decodeFixedBytes("d", raw.D, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := ed25519.NewKeyFromSeed(d)
+	if !publicKey.Equal(privateKey.Public()) {
+		return nil, fmt.Errorf("Ed25519 private key does not match the public key")
+	}
+	return privateKey, nil
+}
+
+// x25519Key creates the X25519 key out of the members.
+func (raw *jwkJSON) x25519Key() (Key, error) {
+	x, err := decodeFixedBytes("x", raw.X, 32)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ecdh.X25519().NewPublicKey(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+	if raw.D == "" {
+		return publicKey, nil
+	}
+	d, err := decodeFixedBytes("d", raw.D, 32)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := ecdh.X25519().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %w", err)
+	}
+	if !publicKey.Equal(privateKey.PublicKey()) {
+		return nil, fmt.Errorf("X25519 private key does not match the public key")
+	}
+	return privateKey, nil
+}
+
+// curveName returns the JWK name of the curve.
+func curveName(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", nil
+	case elliptic.P384():
+		return "P-384", nil
+	case elliptic.P521():
+		return "P-521", nil
+	default:
+		return "", fmt.Errorf("%w: curve '%s' is not supported", ErrInvalidKey, curve.Params().Name)
+	}
+}
+
+// curveByName returns the curves for the JWK name.
+func curveByName(name string) (elliptic.Curve, ecdh.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), ecdh.P256(), nil
+	case "P-384":
+		return elliptic.P384(), ecdh.P384(), nil
+	case "P-521":
+		return elliptic.P521(), ecdh.P521(), nil
+	default:
+		return nil, nil, fmt.Errorf("curve '%s' is not supported", name)
+	}
+}
+
+// encodeBytes encodes bytes to BASE64.
+func encodeBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// encodeInt encodes a big integer to BASE64.
+func encodeInt(i *big.Int) string {
+	return encodeBytes(i.Bytes())
+}
+
+// encodeFixedInt encodes a big integer with a fixed size to BASE64.
+func encodeFixedInt(i *big.Int, size int) string {
+	return encodeBytes(i.FillBytes(make([]byte, size)))
+}
+
+// decodeBytes decodes the BASE64 encoded member.
+func decodeBytes(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing member '%s'", name)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("member '%s' contains invalid data: %w", name, err)
+	}
+	return b, nil
+}
+
+// decodeFixedBytes decodes the BASE64 encoded member and checks
+// its size.
+func decodeFixedBytes(name, value string, size int) ([]byte, error) {
+	b, err := decodeBytes(name, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("member '%s' has size %d, not %d", name, len(b), size)
+	}
+	return b, nil
+}
+
+// decodeInt decodes the BASE64 encoded member as big integer.
+func decodeInt(name, value string) (*big.Int, error) {
+	b, err := decodeBytes(name, value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
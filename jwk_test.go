@@ -0,0 +1,200 @@
+// Tideland Go JSON Web Token - JSON Web Key - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestJWKParseRFC verifies the parsing of the JWK examples of
+// RFC 7515 and RFC 7517 and their usage for verification.
+func TestJWKParseRFC(t *testing.T) {
+	// HMAC key of RFC 7515 appendix A.1.
+	hsJWK, err := jwt.ParseJWK([]byte(`{"kty":"oct",` +
+		`"k":"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow",` +
+		`"kid":"HMAC key used in JWS spec Appendix A.1 example"}`))
+	verify.NoError(t, err)
+	verify.Equal(t, hsJWK.KeyType(), jwt.KeyTypeOct)
+	verify.Equal(t, hsJWK.KeyID, "HMAC key used in JWS spec Appendix A.1 example")
+	verify.True(t, hsJWK.IsPrivate())
+	hsToken := "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9." +
+		"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+		"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	_, err = jwt.Verify(hsToken, hsJWK.Key)
+	verify.NoError(t, err)
+	// ECDSA key of RFC 7515 appendix A.3.
+	esJWK, err := jwt.ParseJWK([]byte(`{"kty":"EC","crv":"P-256",` +
+		`"x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU",` +
+		`"y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}`))
+	verify.NoError(t, err)
+	verify.Equal(t, esJWK.KeyType(), jwt.KeyTypeEC)
+	verify.False(t, esJWK.IsPrivate())
+	esToken := "eyJhbGciOiJFUzI1NiJ9." +
+		"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+		"DtEhU3ljbEg8L38VWAfUAqOyKAM6-Xx-F4GawxaepmXFCgfTjDxw5djxLa8ISlSApmWQxfKTUJqPP3-Kg6NU1Q"
+	_, err = jwt.Verify(esToken, esJWK.Key)
+	verify.NoError(t, err)
+	// Public EC key of RFC 7517 appendix A.1.
+	encJWK, err := jwt.ParseJWK([]byte(`{"kty":"EC","crv":"P-256",` +
+		`"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",` +
+		`"y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",` +
+		`"use":"enc","kid":"1"}`))
+	verify.NoError(t, err)
+	verify.Equal(t, encJWK.KeyID, "1")
+	verify.Equal(t, encJWK.Use, "enc")
+	verify.False(t, encJWK.IsPrivate())
+	// Symmetric key of RFC 7517 appendix A.3.
+	kwJWK, err := jwt.ParseJWK([]byte(`{"kty":"oct","alg":"A128KW","k":"GawgguFyGrWKav7AX4VKUg"}`))
+	verify.NoError(t, err)
+	verify.Equal(t, kwJWK.Algorithm, jwt.Algorithm("A128KW"))
+	verify.Length(t, kwJWK.Key, 16)
+}
+
+// TestJWKRoundTrip verifies the marshalling and unmarshalling of
+// all supported key types.
+func TestJWKRoundTrip(t *testing.T) {
+	es256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	es384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	verify.NoError(t, err)
+	es512Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	verify.NoError(t, err)
+	rsKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	verify.NoError(t, err)
+	hsKey := []byte("secret")
+	tests := []struct {
+		kty        string
+		algorithm  jwt.Algorithm
+		privateKey jwt.Key
+	}{
+		{jwt.KeyTypeEC, jwt.ES256, es256Key},
+		{jwt.KeyTypeEC, jwt.ES384, es384Key},
+		{jwt.KeyTypeEC, jwt.ES512, es512Key},
+		{jwt.KeyTypeRSA, jwt.RS256, rsKey},
+		{jwt.KeyTypeRSA, jwt.PS384, rsKey},
+		{jwt.KeyTypeOKP, jwt.EdDSA, edKey},
+		{jwt.KeyTypeOct, jwt.HS512, hsKey},
+	}
+	for _, test := range tests {
+		jwkIn, err := jwt.NewJWK(jwt.BindKey(test.privateKey, test.algorithm))
+		verify.NoError(t, err)
+		jwkIn.KeyID = "test-" + string(test.algorithm)
+		jwkIn.Use = "sig"
+		jwkIn.KeyOps = []string{"sign", "verify"}
+		verify.Equal(t, jwkIn.KeyType(), test.kty)
+		verify.Equal(t, jwkIn.Algorithm, test.algorithm)
+		// Marshal and unmarshal the private key.
+		jsonValue, err := json.Marshal(jwkIn)
+		verify.NoError(t, err)
+		jwkOut, err := jwt.ParseJWK(jsonValue)
+		verify.NoError(t, err)
+		verify.Equal(t, jwkOut.KeyType(), test.kty)
+		verify.Equal(t, jwkOut.KeyID, jwkIn.KeyID)
+		verify.Equal(t, jwkOut.Use, "sig")
+		verify.Equal(t, strings.Join(jwkOut.KeyOps, ","), "sign,verify")
+		verify.Equal(t, jwkOut.Algorithm, test.algorithm)
+		verify.True(t, jwkOut.IsPrivate())
+		// Sign with the parsed key and verify with the public one.
+		signature, err := test.algorithm.Sign(data, jwkOut.Key)
+		verify.NoError(t, err)
+		verifyJWK := jwkIn
+		if test.kty != jwt.KeyTypeOct {
+			publicJWK, err := jwkIn.Public()
+			verify.NoError(t, err)
+			jsonValue, err = json.Marshal(publicJWK)
+			verify.NoError(t, err)
+			verify.False(t, strings.Contains(string(jsonValue), `"d":`))
+			verifyJWK, err = jwt.ParseJWK(jsonValue)
+			verify.NoError(t, err)
+			verify.False(t, verifyJWK.IsPrivate())
+		}
+		err = test.algorithm.Verify(data, signature, verifyJWK.Key)
+		verify.NoError(t, err)
+	}
+}
+
+// TestJWKThumbprint verifies the JWK thumbprint with the example
+// of RFC 7638.
+func TestJWKThumbprint(t *testing.T) {
+	rsJWK, err := jwt.ParseJWK([]byte(`{"kty":"RSA","alg":"RS256","kid":"2011-04-29",` +
+		`"n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",` +
+		`"e":"AQAB"}`))
+	verify.NoError(t, err)
+	thumbprint, err := rsJWK.Thumbprint(crypto.SHA256)
+	verify.NoError(t, err)
+	verify.Equal(t, base64.RawURLEncoding.EncodeToString(thumbprint), "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+	// Private and public keys have the same thumbprint.
+	esPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	privateJWK, err := jwt.NewJWK(esPrivateKey)
+	verify.NoError(t, err)
+	privateThumbprint, err := privateJWK.Thumbprint(crypto.SHA256)
+	verify.NoError(t, err)
+	publicJWK, err := privateJWK.Public()
+	verify.NoError(t, err)
+	publicJWK.KeyID = "other"
+	publicThumbprint, err := publicJWK.Thumbprint(crypto.SHA256)
+	verify.NoError(t, err)
+	verify.True(t, bytes.Equal(privateThumbprint, publicThumbprint))
+	otherJWK, err := jwt.NewJWK([]byte("secret"))
+	verify.NoError(t, err)
+	otherThumbprint, err := otherJWK.Thumbprint(crypto.SHA256)
+	verify.NoError(t, err)
+	verify.False(t, bytes.Equal(privateThumbprint, otherThumbprint))
+}
+
+// TestJWKInvalid verifies the handling of invalid JWKs.
+func TestJWKInvalid(t *testing.T) {
+	tests := []struct {
+		json  string
+		error string
+	}{
+		{`{"k":"c2VjcmV0"}`, ".*missing key type.*"},
+		{`{"kty":"foo"}`, ".*key type 'foo' is not supported.*"},
+		{`{"kty":"oct"}`, ".*missing member 'k'.*"},
+		{`{"kty":"EC","crv":"P-192","x":"AA","y":"AA"}`, ".*curve 'P-192' is not supported.*"},
+		{`{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}`, ".*member 'x' has size 1, not 32.*"},
+		{`{"kty":"EC","crv":"P-256",` +
+			`"x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU",` +
+			`"y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}`, ".*invalid EC public key.*"},
+		{`{"kty":"EC","crv":"P-256",` +
+			`"x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU",` +
+			`"y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0",` +
+			`"d":"jpsQnnGQmL-YBIffH1136cLSG1KxyEoXt3ob0AAmuHs"}`, ".*private key does not match the public key.*"},
+		{`{"kty":"RSA","n":"AQAB"}`, ".*missing member 'e'.*"},
+		{`{"kty":"RSA","n":"AQAB","e":"AQAB","d":"AQAB"}`, ".*needs the primes.*"},
+		{`{"kty":"OKP","crv":"X448","x":"AA"}`, ".*curve 'X448' is not supported.*"},
+		{`{"kty":"OKP","crv":"Ed25519","x":"!!"}`, ".*member 'x' contains invalid data.*"},
+	}
+	for _, test := range tests {
+		_, err := jwt.ParseJWK([]byte(test.json))
+		verify.ErrorMatch(t, err, test.error)
+	}
+	_, err := jwt.NewJWK("none")
+	verify.ErrorMatch(t, err, ".*key type string is invalid.*")
+	hsJWK, err := jwt.NewJWK([]byte("secret"))
+	verify.NoError(t, err)
+	_, err = hsJWK.Public()
+	verify.ErrorMatch(t, err, ".*has no public key.*")
+}
@@ -15,13 +15,10 @@ import (
 	"time"
 )
 
-// jwtHeader contains the JWT header fields.
-type jwtHeader struct {
-	Algorithm string `json:"alg"`
-	Type      string `json:"typ"`
-}
-
+// JWT contains a JSON Web Token with its header, claims, and
+// the key used for encoding or verification.
 type JWT struct {
+	header    Header
 	claims    Claims
 	key       Key
 	algorithm Algorithm
@@ -29,25 +26,49 @@ type JWT struct {
 }
 
 // Encode creates a JSON Web Token for the given claims
-// based on key and algorithm.
-func Encode(claims Claims, key Key, algorithm Algorithm) (*JWT, error) {
+// based on key and algorithm. Additional header parameters
+// can be passed with WithHeader. The "typ" defaults to "JWT",
+// in case of a JWK with a key ID it is set as "kid". With
+// WithLifetime the times of the claims are set based on the
+// clock passed with WithClock.
+func Encode(claims Claims, key Key, algorithm Algorithm, options ...Option) (*JWT, error) {
+	o := newOptions(options)
+	if o.lifetime > 0 {
+		now := o.clock.Now()
+		claims = copyClaims(claims)
+		claims.SetIssuedAt(now)
+		claims.SetExpiration(now.Add(o.lifetime))
+	}
+	signKey, err := unbindKey(key, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode the token: %w", err)
+	}
+	header := copyHeader(o.header)
+	header.Set("alg", string(algorithm))
+	if !header.Contains("typ") {
+		header.SetType("JWT")
+	}
+	if jwk, ok := key.(*JWK); ok && jwk.KeyID != "" && !header.Contains("kid") {
+		header.SetKeyID(jwk.KeyID)
+	}
 	jwt := &JWT{
+		header:    header,
 		claims:    claims,
 		key:       key,
 		algorithm: algorithm,
 	}
-	headerPart, err := marshallAndEncode(jwtHeader{string(algorithm), "JWT"})
+	headerPart, err := marshallAndEncode(header)
 	if err != nil {
-		return nil, fmt.Errorf("cannot encode the header: %v", err)
+		return nil, fmt.Errorf("cannot encode the header: %w", err)
 	}
 	claimsPart, err := marshallAndEncode(claims)
 	if err != nil {
-		return nil, fmt.Errorf("cannot encode the claims: %v", err)
+		return nil, fmt.Errorf("cannot encode the claims: %w", err)
 	}
 	dataParts := headerPart + "." + claimsPart
-	signaturePart, err := signAndEncode([]byte(dataParts), key, algorithm)
+	signaturePart, err := signAndEncode([]byte(dataParts), signKey, algorithm)
 	if err != nil {
-		return nil, fmt.Errorf("cannot encode the signature: %v", err)
+		return nil, fmt.Errorf("cannot encode the signature: %w", err)
 	}
 	jwt.token = dataParts + "." + signaturePart
 	return jwt, nil
@@ -57,52 +78,68 @@ func Encode(claims Claims, key Key, algorithm Algorithm) (*JWT, error) {
 func Decode(token string) (*JWT, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("cannot decode the parts")
+		return nil, fmt.Errorf("cannot decode the parts: %w", ErrMalformedToken)
 	}
-	var header jwtHeader
+	var header Header
 	err := decodeAndUnmarshall(parts[0], &header)
 	if err != nil {
-		return nil, fmt.Errorf("cannot decode the header: %v", err)
+		return nil, fmt.Errorf("cannot decode the header: %w", err)
 	}
 	var claims Claims
 	err = decodeAndUnmarshall(parts[1], &claims)
 	if err != nil {
-		return nil, fmt.Errorf("cannot decode the claims: %v", err)
+		return nil, fmt.Errorf("cannot decode the claims: %w", err)
 	}
 	return &JWT{
+		header:    header,
 		claims:    claims,
-		algorithm: Algorithm(header.Algorithm),
+		algorithm: header.Algorithm(),
 		token:     token,
 	}, nil
 }
 
 // Verify creates a token out of a string and varifies it against
-// the passed key.
-func Verify(token string, key Key) (*JWT, error) {
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("cannot verify the parts")
+// the passed key. The algorithm named in the token header has to be
+// permitted by the options and, in case of a BoundKey or a JWK with
+// algorithm, match the algorithm of the key. Otherwise the token is
+// rejected before any cryptography runs. Without options all
+// algorithms except "none" are permitted. Tokens with extensions in
+// the "crit" header parameter are only accepted if these have been
+// declared with WithCritical. If the key is a KeySource the
+// verification is done like by VerifyWithKeys.
+func Verify(token string, key Key, options ...Option) (*JWT, error) {
+	if source, ok := key.(KeySource); ok {
+		return VerifyWithKeys(token, source, options...)
 	}
-	var header jwtHeader
-	err := decodeAndUnmarshall(parts[0], &header)
-	if err != nil {
-		return nil, fmt.Errorf("cannot verify the header: %v", err)
+	lookup := func(header Header) ([]Key, error) {
+		return []Key{key}, nil
 	}
-	err = decodeAndVerify(parts, key, Algorithm(header.Algorithm))
-	if err != nil {
-		return nil, fmt.Errorf("cannot verify the signature: %v", err)
-	}
-	var claims Claims
-	err = decodeAndUnmarshall(parts[1], &claims)
-	if err != nil {
-		return nil, fmt.Errorf("cannot verify the claims: %v", err)
+	return verify(token, lookup, newOptions(options))
+}
+
+// VerifyWithKeys creates a token out of a string and verifies it
+// against the keys of the source. These are selected by the key ID
+// of the token header or, if it has none, by the algorithm. The key
+// used for the successful verification is returned by JWT.Key().
+func VerifyWithKeys(token string, source KeySource, options ...Option) (*JWT, error) {
+	lookup := func(header Header) ([]Key, error) {
+		kid, _ := header.KeyID()
+		jwks, err := source.LookupKeys(kid, header.Algorithm())
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]Key, len(jwks))
+		for i, jwk := range jwks {
+			keys[i] = jwk.verificationJWK()
+		}
+		return keys, nil
 	}
-	return &JWT{
-		claims:    claims,
-		key:       key,
-		algorithm: Algorithm(header.Algorithm),
-		token:     token,
-	}, nil
+	return verify(token, lookup, newOptions(options))
+}
+
+// Header returns the header parameters of the token.
+func (jwt *JWT) Header() Header {
+	return jwt.header
 }
 
 // Claims returns the claims payload of the token.
@@ -113,7 +150,7 @@ func (jwt *JWT) Claims() Claims {
 // Key returns the key of the token only when it is a result of encoding or verification.
 func (jwt *JWT) Key() (Key, error) {
 	if jwt.key == nil {
-		return nil, fmt.Errorf("no key available, only after encoding or verifying")
+		return nil, fmt.Errorf("%w: no key available, only after encoding or verifying", ErrKeyNotFound)
 	}
 	return jwt.key, nil
 }
@@ -128,17 +165,89 @@ func (jwt *JWT) IsValid(leeway time.Duration) bool {
 	return jwt.claims.IsValid(leeway)
 }
 
+// IsValidAt is a convenience method checking the registered claims if the token is
+// valid at the passed time.
+func (jwt *JWT) IsValidAt(now time.Time, leeway time.Duration) bool {
+	return jwt.claims.IsValidAt(now, leeway)
+}
+
 // String implements the fmt.Stringer interface.
 func (jwt *JWT) String() string {
 	return jwt.token
 }
 
+// verify verifies the token against the keys returned by the lookup
+// function. The first key successfully verifying the signature wins.
+func verify(token string, lookup func(header Header) ([]Key, error), o *options) (*JWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("cannot verify the parts: %w", ErrMalformedToken)
+	}
+	var header Header
+	err := decodeAndUnmarshall(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify the header: %w", err)
+	}
+	algorithm := header.Algorithm()
+	if _, err = lookupMethod(algorithm); err != nil {
+		return nil, fmt.Errorf("cannot verify the header: %w", err)
+	}
+	if !o.permits(algorithm) {
+		return nil, fmt.Errorf("cannot verify the header: %w", &AlgorithmError{Algorithm: algorithm, Err: ErrAlgorithmNotPermitted})
+	}
+	if err = checkCritical(header, o.critical); err != nil {
+		return nil, fmt.Errorf("cannot verify the header: %w", err)
+	}
+	keys, err := lookup(header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify the key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("cannot verify the key: %w: no key for algorithm '%s'", ErrKeyNotFound, algorithm)
+	}
+	var key Key
+	for _, key = range keys {
+		var verifyKey Key
+		verifyKey, err = unbindKey(key, algorithm)
+		if err != nil {
+			err = fmt.Errorf("cannot verify the header: %w", err)
+			continue
+		}
+		err = decodeAndVerify(parts, o.verifyKey(verifyKey), algorithm)
+		if err != nil {
+			err = fmt.Errorf("cannot verify the signature: %w", err)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	err = decodeAndUnmarshall(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify the claims: %w", err)
+	}
+	if o.validator != nil {
+		if err = o.validator.ValidateAt(claims, o.clock.Now()); err != nil {
+			return nil, fmt.Errorf("cannot verify the claims: %w", err)
+		}
+	}
+	return &JWT{
+		header:    header,
+		claims:    claims,
+		key:       key,
+		algorithm: algorithm,
+		token:     token,
+	}, nil
+}
+
 // marshallAndEncode marshals the passed value to JSON and
 // creates a BASE64 string out of it.
 func marshallAndEncode(value interface{}) (string, error) {
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
-		return "", fmt.Errorf("error marshalling to JSON: %v", err)
+		return "", fmt.Errorf("error marshalling to JSON: %w", err)
 	}
 	encoded := base64.RawURLEncoding.EncodeToString(jsonValue)
 	return encoded, nil
@@ -149,11 +258,11 @@ func marshallAndEncode(value interface{}) (string, error) {
 func decodeAndUnmarshall(part string, value interface{}) error {
 	decoded, err := base64.RawURLEncoding.DecodeString(part)
 	if err != nil {
-		return fmt.Errorf("part of the token contains invalid data: %v", err)
+		return fmt.Errorf("%w: part of the token contains invalid data: %w", ErrMalformedToken, err)
 	}
 	err = json.Unmarshal(decoded, value)
 	if err != nil {
-		return fmt.Errorf("error unmarshalling from JSON: %v", err)
+		return fmt.Errorf("%w: error unmarshalling from JSON: %w", ErrMalformedToken, err)
 	}
 	return nil
 }
@@ -177,7 +286,7 @@ func decodeAndVerify(parts []string, key Key, algorithm Algorithm) error {
 	data := []byte(parts[0] + "." + parts[1])
 	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
 	if err != nil {
-		return fmt.Errorf("part of the token contains invalid data: %v", err)
+		return fmt.Errorf("%w: part of the token contains invalid data: %w", ErrMalformedToken, err)
 	}
 	return algorithm.Verify(data, sig, key)
 }
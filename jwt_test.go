@@ -8,6 +8,12 @@
 package jwt_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
 	"testing"
 	"time"
 
@@ -98,3 +104,103 @@ func TestIsValid(t *testing.T) {
 	ok = tokenDec.IsValid(leeway)
 	verify.False(t, ok)
 }
+
+// TestVerifyAlgorithms verifies the restriction of the algorithms
+// accepted during verification.
+func TestVerifyAlgorithms(t *testing.T) {
+	key := []byte("secret")
+	claims := jwt.NewClaims()
+	claims.SetSubject(subClaim)
+	tokenEnc, err := jwt.Encode(claims, key, jwt.HS512)
+	verify.NoError(t, err)
+	// Default and explicit permission.
+	tokenVer, err := jwt.Verify(tokenEnc.String(), key)
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.Algorithm(), jwt.HS512)
+	tokenVer, err = jwt.Verify(tokenEnc.String(), key, jwt.WithAlgorithms(jwt.HS256, jwt.HS512))
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.Algorithm(), jwt.HS512)
+	// Algorithm not in the allow-list.
+	_, err = jwt.Verify(tokenEnc.String(), key, jwt.WithAlgorithms(jwt.HS256))
+	verify.ErrorMatch(t, err, ".*algorithm 'HS512' is not permitted.*")
+	// Bound keys.
+	tokenVer, err = jwt.Verify(tokenEnc.String(), jwt.BindKey(key, jwt.HS512))
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.Algorithm(), jwt.HS512)
+	_, err = jwt.Verify(tokenEnc.String(), jwt.BindKey(key, jwt.HS256))
+	verify.ErrorMatch(t, err, ".*key is bound to algorithm 'HS256', not 'HS512'.*")
+	_, err = jwt.Encode(claims, jwt.BindKey(key, jwt.HS256), jwt.HS512)
+	verify.ErrorMatch(t, err, ".*key is bound to algorithm 'HS256', not 'HS512'.*")
+	// Bound JWKs.
+	hsJWK, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), jwt.BindKey(hsJWK, jwt.HS512))
+	verify.NoError(t, err)
+	_, err = jwt.Encode(claims, jwt.BindKey(hsJWK, jwt.HS512), jwt.HS512)
+	verify.NoError(t, err)
+	hsJWK.Algorithm = jwt.HS256
+	_, err = jwt.Verify(tokenEnc.String(), jwt.BindKey(hsJWK, jwt.HS512))
+	verify.ErrorMatch(t, err, ".*key is bound to algorithm 'HS256', not 'HS512'.*")
+	// Algorithm "none" only when explicitly permitted.
+	tokenEnc, err = jwt.Encode(claims, "", jwt.NONE)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), "")
+	verify.ErrorMatch(t, err, ".*algorithm 'none' is not permitted.*")
+	_, err = jwt.Verify(tokenEnc.String(), key)
+	verify.ErrorMatch(t, err, ".*algorithm 'none' is not permitted.*")
+	tokenVer, err = jwt.Verify(tokenEnc.String(), "", jwt.WithAlgorithms(jwt.NONE))
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.Algorithm(), jwt.NONE)
+}
+
+// TestVerifyES256Interop verifies an ECDSA signed token of RFC 7515
+// appendix A.3 to ensure interoperability.
+func TestVerifyES256Interop(t *testing.T) {
+	token := "eyJhbGciOiJFUzI1NiJ9." +
+		"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+		"DtEhU3ljbEg8L38VWAfUAqOyKAM6-Xx-F4GawxaepmXFCgfTjDxw5djxLa8ISlSApmWQxfKTUJqPP3-Kg6NU1Q"
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     decodeBigInt(t, "f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU"),
+		Y:     decodeBigInt(t, "x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"),
+	}
+	tokenVer, err := jwt.Verify(token, publicKey)
+	verify.NoError(t, err)
+	iss, ok := tokenVer.Claims().Issuer()
+	verify.True(t, ok)
+	verify.Equal(t, iss, "joe")
+}
+
+// TestVerifyLegacyECDSA verifies the opt-in acceptance of ASN.1 DER
+// encoded ECDSA signatures.
+func TestVerifyLegacyECDSA(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	data := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234567890"}`))
+	hasher := crypto.SHA256.New()
+	hasher.Write([]byte(data))
+	derSignature, err := ecdsa.SignASN1(rand.Reader, privateKey, hasher.Sum(nil))
+	verify.NoError(t, err)
+	token := data + "." + base64.RawURLEncoding.EncodeToString(derSignature)
+	// Rejected by default, accepted in legacy mode.
+	_, err = jwt.Verify(token, privateKey.Public())
+	verify.ErrorMatch(t, err, ".*data signature is invalid.*")
+	tokenVer, err := jwt.Verify(token, privateKey.Public(), jwt.WithLegacyECDSA())
+	verify.NoError(t, err)
+	sub, ok := tokenVer.Claims().Subject()
+	verify.True(t, ok)
+	verify.Equal(t, sub, subClaim)
+	// New tokens are still accepted in legacy mode.
+	tokenEnc, err := jwt.Encode(tokenVer.Claims(), privateKey, jwt.ES256)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), privateKey.Public(), jwt.WithLegacyECDSA())
+	verify.NoError(t, err)
+}
+
+// decodeBigInt decodes a BASE64 encoded big integer.
+func decodeBigInt(t *testing.T, s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	verify.NoError(t, err)
+	return new(big.Int).SetBytes(b)
+}
@@ -9,6 +9,7 @@ package jwt
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -18,23 +19,61 @@ import (
 )
 
 // Key is the used key to sign a token. The real implementation
-// controls signing and verification.
+// controls signing and verification. Beside the private keys any
+// crypto.Signer with an RSA, ECDSA, or Ed25519 public key can be
+// used for signing.
 type Key interface{}
 
+// BoundKey binds a key to exactly one algorithm. When passed to
+// Verify only tokens signed with this algorithm are accepted.
+type BoundKey struct {
+	Key       Key
+	Algorithm Algorithm
+}
+
+// BindKey binds the key to the passed algorithm.
+func BindKey(key Key, algorithm Algorithm) *BoundKey {
+	return &BoundKey{
+		Key:       key,
+		Algorithm: algorithm,
+	}
+}
+
+// unbindKey returns the key to use for the algorithm. In case of a
+// bound key or a JWK with algorithm the algorithm has to match, also
+// for a JWK bound to an algorithm.
+func unbindKey(key Key, algorithm Algorithm) (Key, error) {
+	switch k := key.(type) {
+	case *BoundKey:
+		if k.Algorithm != algorithm {
+			return nil, fmt.Errorf("%w: key is bound to algorithm '%s', not '%s'", ErrAlgorithmNotPermitted, k.Algorithm, algorithm)
+		}
+		// The bound key may be a JWK with its own algorithm.
+		return unbindKey(k.Key, algorithm)
+	case *JWK:
+		if k.Algorithm != "" && k.Algorithm != algorithm {
+			return nil, fmt.Errorf("%w: key is bound to algorithm '%s', not '%s'", ErrAlgorithmNotPermitted, k.Algorithm, algorithm)
+		}
+		return k.Key, nil
+	default:
+		return key, nil
+	}
+}
+
 // ReadECPrivateKey reads a PEM formated ECDSA private key
 // from the passed reader.
 func ReadECPrivateKey(r io.Reader) (Key, error) {
 	pemkey, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read the PEM")
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
 	}
 	var block *pem.Block
 	if block, _ = pem.Decode(pemkey); block == nil {
-		return nil, fmt.Errorf("cannot decode the PEM")
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
 	}
 	var parsed *ecdsa.PrivateKey
 	if parsed, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
-		return nil, fmt.Errorf("cannot parse the ECDSA: %v", err)
+		return nil, fmt.Errorf("%w: cannot parse the ECDSA: %w", ErrInvalidKey, err)
 	}
 	return parsed, nil
 }
@@ -44,24 +83,74 @@ func ReadECPrivateKey(r io.Reader) (Key, error) {
 func ReadECPublicKey(r io.Reader) (Key, error) {
 	pemkey, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read the PEM")
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
 	}
 	var block *pem.Block
 	if block, _ = pem.Decode(pemkey); block == nil {
-		return nil, fmt.Errorf("cannot decode the PEM")
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
 	}
 	var parsed interface{}
 	parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		certificate, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
-			return nil, fmt.Errorf("cannot parse the ECDSA: %v", err)
+			return nil, fmt.Errorf("%w: cannot parse the ECDSA: %w", ErrInvalidKey, err)
 		}
 		parsed = certificate.PublicKey
 	}
 	publicKey, ok := parsed.(*ecdsa.PublicKey)
 	if !ok {
-		return nil, fmt.Errorf("passed key is no ECDSA key")
+		return nil, fmt.Errorf("%w: passed key is no ECDSA key", ErrInvalidKey)
+	}
+	return publicKey, nil
+}
+
+// ReadEd25519PrivateKey reads a PEM encoded PKCS8 Ed25519 private key
+// from the passed reader.
+func ReadEd25519PrivateKey(r io.Reader) (Key, error) {
+	pemkey, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
+	}
+	var block *pem.Block
+	if block, _ = pem.Decode(pemkey); block == nil {
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
+	}
+	var parsed interface{}
+	parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot parse the Ed25519: %w", ErrInvalidKey, err)
+	}
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: passed key is no Ed25519 key", ErrInvalidKey)
+	}
+	return privateKey, nil
+}
+
+// ReadEd25519PublicKey reads a PEM encoded PKIX Ed25519 public key
+// from the passed reader.
+func ReadEd25519PublicKey(r io.Reader) (Key, error) {
+	pemkey, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
+	}
+	var block *pem.Block
+	if block, _ = pem.Decode(pemkey); block == nil {
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
+	}
+	var parsed interface{}
+	parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot parse the Ed25519: %w", ErrInvalidKey, err)
+		}
+		parsed = certificate.PublicKey
+	}
+	publicKey, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: passed key is no Ed25519 key", ErrInvalidKey)
 	}
 	return publicKey, nil
 }
@@ -71,23 +160,23 @@ func ReadECPublicKey(r io.Reader) (Key, error) {
 func ReadRSAPrivateKey(r io.Reader) (Key, error) {
 	pemkey, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read the PEM")
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
 	}
 	var block *pem.Block
 	if block, _ = pem.Decode(pemkey); block == nil {
-		return nil, fmt.Errorf("cannot decode the PEM")
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
 	}
 	var parsed interface{}
 	parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
 		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
 		if err != nil {
-			return nil, fmt.Errorf("cannot parse the RSA: %v", err)
+			return nil, fmt.Errorf("%w: cannot parse the RSA: %w", ErrInvalidKey, err)
 		}
 	}
 	privateKey, ok := parsed.(*rsa.PrivateKey)
 	if !ok {
-		return nil, fmt.Errorf("passed key is no RSA key")
+		return nil, fmt.Errorf("%w: passed key is no RSA key", ErrInvalidKey)
 	}
 	return privateKey, nil
 }
@@ -97,24 +186,24 @@ func ReadRSAPrivateKey(r io.Reader) (Key, error) {
 func ReadRSAPublicKey(r io.Reader) (Key, error) {
 	pemkey, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read the PEM")
+		return nil, fmt.Errorf("%w: cannot read the PEM: %w", ErrInvalidKey, err)
 	}
 	var block *pem.Block
 	if block, _ = pem.Decode(pemkey); block == nil {
-		return nil, fmt.Errorf("cannot decode the PEM")
+		return nil, fmt.Errorf("%w: cannot decode the PEM", ErrInvalidKey)
 	}
 	var parsed interface{}
 	parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		certificate, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
-			return nil, fmt.Errorf("cannot parse the RSA: %v", err)
+			return nil, fmt.Errorf("%w: cannot parse the RSA: %w", ErrInvalidKey, err)
 		}
 		parsed = certificate.PublicKey
 	}
 	publicKey, ok := parsed.(*rsa.PublicKey)
 	if !ok {
-		return nil, fmt.Errorf("passed key is no RSA key")
+		return nil, fmt.Errorf("%w: passed key is no RSA key", ErrInvalidKey)
 	}
 	return publicKey, nil
 }
@@ -0,0 +1,170 @@
+// Tideland Go JSON Web Token - JSON Web Key Set
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// KeySource provides the candidate keys for the verification of
+// a token based on the key ID and the algorithm of its header.
+type KeySource interface {
+	// LookupKeys returns the keys matching the key ID. In case of
+	// an empty key ID all keys usable with the algorithm are returned.
+	LookupKeys(kid string, algorithm Algorithm) ([]*JWK, error)
+}
+
+// KeySet is a JSON Web Key Set as defined in RFC 7517. It can be
+// used as KeySource for the verification.
+type KeySet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// NewKeySet creates a key set containing the passed keys.
+func NewKeySet(jwks ...*JWK) *KeySet {
+	return &KeySet{
+		Keys: jwks,
+	}
+}
+
+// ParseKeySet parses the passed JSON encoded JSON Web Key Set.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var set KeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		if !errors.Is(err, ErrInvalidKey) {
+			// Syntax errors are returned before UnmarshalJSON.
+			err = fmt.Errorf("cannot unmarshal the JWK set: %w: %w", ErrInvalidKey, err)
+		}
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ReadKeySet reads a JSON encoded JSON Web Key Set from the
+// passed reader.
+func ReadKeySet(r io.Reader) (*KeySet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the JWK set: %w", err)
+	}
+	return ParseKeySet(data)
+}
+
+// Add adds keys to the set.
+func (s *KeySet) Add(jwks ...*JWK) {
+	s.Keys = append(s.Keys, jwks...)
+}
+
+// Lookup returns the first key with the given key ID.
+func (s *KeySet) Lookup(kid string) (*JWK, bool) {
+	for _, jwk := range s.Keys {
+		if jwk.KeyID == kid {
+			return jwk, true
+		}
+	}
+	return nil, false
+}
+
+// LookupKeys implements the KeySource interface.
+func (s *KeySet) LookupKeys(kid string, algorithm Algorithm) ([]*JWK, error) {
+	var jwks []*JWK
+	for _, jwk := range s.Keys {
+		if kid != "" && jwk.KeyID != kid {
+			continue
+		}
+		if jwk.canVerify(algorithm) {
+			jwks = append(jwks, jwk)
+		}
+	}
+	if len(jwks) == 0 {
+		if kid != "" {
+			return nil, fmt.Errorf("%w: no key with ID '%s' for algorithm '%s'", ErrKeyNotFound, kid, algorithm)
+		}
+		return nil, fmt.Errorf("%w: no key for algorithm '%s'", ErrKeyNotFound, algorithm)
+	}
+	return jwks, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Keys
+// which cannot be parsed, e.g. due to unsupported key types, are
+// ignored as recommended by RFC 7517.
+func (s *KeySet) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("cannot unmarshal the JWK set: %w: %w", ErrInvalidKey, err)
+	}
+	if raw.Keys == nil {
+		return fmt.Errorf("cannot unmarshal the JWK set: %w: missing member 'keys'", ErrInvalidKey)
+	}
+	s.Keys = nil
+	for _, rawKey := range raw.Keys {
+		jwk, err := ParseJWK(rawKey)
+		if err != nil {
+			continue
+		}
+		s.Keys = append(s.Keys, jwk)
+	}
+	return nil
+}
+
+// canVerify checks if the JWK may be used for the verification
+// of a token signed with the algorithm.
+func (jwk *JWK) canVerify(algorithm Algorithm) bool {
+	if jwk.Algorithm != "" && jwk.Algorithm != algorithm {
+		return false
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return false
+	}
+	if len(jwk.KeyOps) > 0 && !slices.Contains(jwk.KeyOps, "verify") {
+		return false
+	}
+	if _, err := lookupMethod(algorithm); err != nil {
+		return false
+	}
+	if algorithm.isECDSA() {
+		switch key := jwk.Key.(type) {
+		case *ecdsa.PublicKey:
+			if algorithm.checkCurve(key) != nil {
+				return false
+			}
+		case *ecdsa.PrivateKey:
+			if algorithm.checkCurve(&key.PublicKey) != nil {
+				return false
+			}
+		}
+	}
+	if algorithm.keyType() == "" {
+		return true
+	}
+	kty, err := jwk.keyType()
+	if err != nil {
+		return false
+	}
+	return kty == algorithm.keyType()
+}
+
+// verificationJWK returns the JWK with only the public key if it
+// contains a private asymmetric key.
+func (jwk *JWK) verificationJWK() *JWK {
+	if _, isSymmetric := jwk.Key.([]byte); isSymmetric || !jwk.IsPrivate() {
+		return jwk
+	}
+	public, err := jwk.Public()
+	if err != nil {
+		return jwk
+	}
+	return public
+}
@@ -0,0 +1,159 @@
+// Tideland Go JSON Web Token - JSON Web Key Set - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestKeySetVerify verifies the selection of the keys by key ID
+// during the verification.
+func TestKeySetVerify(t *testing.T) {
+	rsKey, esKey, hsKey := generateJWKs(t)
+	set := jwt.NewKeySet(publicJWK(t, rsKey), publicJWK(t, esKey), hsKey)
+	claims := initClaims()
+	for _, signKey := range []*jwt.JWK{rsKey, esKey, hsKey} {
+		tokenEnc, err := jwt.Encode(claims, signKey, signKey.Algorithm)
+		verify.NoError(t, err)
+		tokenVer, err := jwt.VerifyWithKeys(tokenEnc.String(), set)
+		verify.NoError(t, err)
+		verify.Equal(t, tokenVer.Algorithm(), signKey.Algorithm)
+		key, err := tokenVer.Key()
+		verify.NoError(t, err)
+		verify.Equal(t, key.(*jwt.JWK).KeyID, signKey.KeyID)
+		// Sets can be passed as key too.
+		tokenVer, err = jwt.Verify(tokenEnc.String(), set)
+		verify.NoError(t, err)
+		verify.Equal(t, tokenVer.Algorithm(), signKey.Algorithm)
+	}
+	// Unknown key ID.
+	unknownKey, err := jwt.NewJWK([]byte("unknown"))
+	verify.NoError(t, err)
+	unknownKey.KeyID = "unknown"
+	tokenEnc, err := jwt.Encode(claims, unknownKey, jwt.HS256)
+	verify.NoError(t, err)
+	_, err = jwt.VerifyWithKeys(tokenEnc.String(), set)
+	verify.ErrorMatch(t, err, ".*no key with ID 'unknown' for algorithm 'HS256'.*")
+	// No key ID and no key for the algorithm.
+	tokenEnc, err = jwt.Encode(claims, jwt.BindKey(hsKey.Key, jwt.HS256), jwt.HS256)
+	verify.NoError(t, err)
+	_, err = jwt.VerifyWithKeys(tokenEnc.String(), set)
+	verify.ErrorMatch(t, err, ".*no key for algorithm 'HS256'.*")
+	// Algorithm restriction is checked before the lookup.
+	tokenEnc, err = jwt.Encode(claims, rsKey, jwt.RS256)
+	verify.NoError(t, err)
+	_, err = jwt.VerifyWithKeys(tokenEnc.String(), set, jwt.WithAlgorithms(jwt.ES256))
+	verify.ErrorMatch(t, err, ".*algorithm 'RS256' is not permitted.*")
+}
+
+// TestKeySetFallback verifies the selection of the keys by algorithm
+// and key type in case of tokens without key ID.
+func TestKeySetFallback(t *testing.T) {
+	rsKeyA, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	rsKeyB, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	esKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	set := jwt.NewKeySet()
+	for i, key := range []jwt.Key{&rsKeyA.PublicKey, &esKey.PublicKey, &rsKeyB.PublicKey} {
+		jwk, err := jwt.NewJWK(key)
+		verify.NoError(t, err)
+		jwk.KeyID = string(rune('a' + i))
+		set.Add(jwk)
+	}
+	claims := initClaims()
+	// Signed by the second RSA key, no key ID.
+	tokenEnc, err := jwt.Encode(claims, rsKeyB, jwt.PS256)
+	verify.NoError(t, err)
+	tokenVer, err := jwt.VerifyWithKeys(tokenEnc.String(), set)
+	verify.NoError(t, err)
+	key, err := tokenVer.Key()
+	verify.NoError(t, err)
+	verify.Equal(t, key.(*jwt.JWK).KeyID, "c")
+	// Signed by none of the keys.
+	rsKeyC, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	tokenEnc, err = jwt.Encode(claims, rsKeyC, jwt.RS256)
+	verify.NoError(t, err)
+	_, err = jwt.VerifyWithKeys(tokenEnc.String(), set)
+	verify.ErrorMatch(t, err, ".*cannot verify the signature.*")
+}
+
+// TestKeySetMarshalling verifies the marshalling of key sets.
+func TestKeySetMarshalling(t *testing.T) {
+	rsKey, esKey, hsKey := generateJWKs(t)
+	setIn := jwt.NewKeySet(publicJWK(t, rsKey), publicJWK(t, esKey), hsKey)
+	jsonValue, err := json.Marshal(setIn)
+	verify.NoError(t, err)
+	setOut, err := jwt.ParseKeySet(jsonValue)
+	verify.NoError(t, err)
+	verify.Length(t, setOut.Keys, 3)
+	jwk, ok := setOut.Lookup(esKey.KeyID)
+	verify.True(t, ok)
+	verify.Equal(t, jwk.KeyType(), jwt.KeyTypeEC)
+	_, ok = setOut.Lookup("unknown")
+	verify.False(t, ok)
+	// Unsupported keys are ignored.
+	setOut, err = jwt.ParseKeySet([]byte(`{"keys":[{"kty":"foo","kid":"1"},{"kty":"oct","kid":"2","k":"c2VjcmV0"}]}`))
+	verify.NoError(t, err)
+	verify.Length(t, setOut.Keys, 1)
+	verify.Equal(t, setOut.Keys[0].KeyID, "2")
+	_, err = jwt.ParseKeySet([]byte(`{"foo":[]}`))
+	verify.ErrorMatch(t, err, ".*missing member 'keys'.*")
+}
+
+// TestKeySetRequestVerify verifies the verification of request
+// tokens using a key set.
+func TestKeySetRequestVerify(t *testing.T) {
+	rsKey, esKey, hsKey := generateJWKs(t)
+	set := jwt.NewKeySet(publicJWK(t, rsKey), publicJWK(t, esKey), hsKey)
+	tokenEnc, err := jwt.Encode(initClaims(), esKey, esKey.Algorithm)
+	verify.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	verify.NoError(t, err)
+	req = jwt.RequestAdd(req, tokenEnc)
+	tokenVer, err := jwt.RequestVerify(req, set)
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.String(), tokenEnc.String())
+}
+
+// generateJWKs creates private RSA, ECDSA, and HMAC JWKs.
+func generateJWKs(t *testing.T) (*jwt.JWK, *jwt.JWK, *jwt.JWK) {
+	rsPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	verify.NoError(t, err)
+	rsKey, err := jwt.NewJWK(jwt.BindKey(rsPrivateKey, jwt.RS256))
+	verify.NoError(t, err)
+	rsKey.KeyID = "rs"
+	esPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	verify.NoError(t, err)
+	esKey, err := jwt.NewJWK(jwt.BindKey(esPrivateKey, jwt.ES256))
+	verify.NoError(t, err)
+	esKey.KeyID = "es"
+	hsKey, err := jwt.NewJWK(jwt.BindKey([]byte("secret"), jwt.HS512))
+	verify.NoError(t, err)
+	hsKey.KeyID = "hs"
+	return rsKey, esKey, hsKey
+}
+
+// publicJWK returns the public part of a JWK.
+func publicJWK(t *testing.T, jwk *jwt.JWK) *jwt.JWK {
+	public, err := jwk.Public()
+	verify.NoError(t, err)
+	return public
+}
@@ -0,0 +1,204 @@
+// Tideland Go JSON Web Token - Options
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Option configures the handling of tokens, e.g. during the
+// verification. Options not used by an operation are ignored, e.g.
+// the cache options WithMaxBytes, WithCacheObserver, and WithCacheStore
+// are only used by NewCache.
+type Option func(o *options)
+
+// options contains the collected configuration of the options.
+type options struct {
+	algorithms    []Algorithm
+	keyManagement []KeyManagement
+	encryption    []ContentEncryption
+	legacyECDSA   bool
+	httpClient    *http.Client
+	header        Header
+	critical      map[string]CriticalHandler
+	validator     *Validator
+	clock         Clock
+	lifetime      time.Duration
+	maxBytes      int
+	cacheObserver CacheObserver
+	cacheStore    CacheStore
+	cacheSecret   []byte
+}
+
+// newOptions applies the passed options to a fresh configuration.
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock: SystemClock(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithAlgorithms restricts the algorithms accepted during the
+// verification to the passed ones. Tokens signed with any other
+// algorithm are rejected before the signature is checked. Without
+// this option all algorithms except "none" are accepted.
+func WithAlgorithms(algorithms ...Algorithm) Option {
+	return func(o *options) {
+		o.algorithms = append(o.algorithms, algorithms...)
+	}
+}
+
+// WithKeyManagement restricts the key management algorithms accepted
+// during the decryption to the passed ones. Without this option all
+// supported key management algorithms are accepted.
+func WithKeyManagement(keyManagement ...KeyManagement) Option {
+	return func(o *options) {
+		o.keyManagement = append(o.keyManagement, keyManagement...)
+	}
+}
+
+// WithContentEncryption restricts the content encryption algorithms
+// accepted during the decryption to the passed ones. Without this
+// option all supported content encryption algorithms are accepted.
+func WithContentEncryption(encryption ...ContentEncryption) Option {
+	return func(o *options) {
+		o.encryption = append(o.encryption, encryption...)
+	}
+}
+
+// WithHeader adds the parameters of the header to the header of an
+// encoded token. The "alg" is always set to the algorithm used for
+// signing.
+func WithHeader(header Header) Option {
+	return func(o *options) {
+		if o.header == nil {
+			o.header = NewHeader()
+		}
+		for key, value := range header {
+			o.header.Set(key, value)
+		}
+	}
+}
+
+// WithCritical declares the header extension with the given name
+// as understood during the verification. Tokens listing it in their
+// "crit" header parameter are passed to the handler, those listing
+// undeclared extensions are rejected.
+func WithCritical(name string, handler CriticalHandler) Option {
+	return func(o *options) {
+		if o.critical == nil {
+			o.critical = make(map[string]CriticalHandler)
+		}
+		o.critical[name] = handler
+	}
+}
+
+// WithValidator lets the verification check the claims of the
+// token with the validator after the signature has been verified.
+func WithValidator(validator *Validator) Option {
+	return func(o *options) {
+		o.validator = validator
+	}
+}
+
+// WithClock sets the clock used for all time based operations,
+// e.g. the validation of claims, the expiration of cached tokens,
+// or the setting of times when encoding.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithLifetime lets the encoding set the "iat" claim to the current
+// time and the "exp" claim to the current time plus the lifetime.
+// The passed claims are not changed.
+func WithLifetime(lifetime time.Duration) Option {
+	return func(o *options) {
+		o.lifetime = lifetime
+	}
+}
+
+// WithMaxBytes limits the total length of the tokens stored in a
+// Cache. Without this option only the number of entries is limited.
+func WithMaxBytes(bytes int) Option {
+	return func(o *options) {
+		o.maxBytes = bytes
+	}
+}
+
+// WithCacheObserver sets the observer notified about the events
+// of a Cache.
+func WithCacheObserver(observer CacheObserver) Option {
+	return func(o *options) {
+		o.cacheObserver = observer
+	}
+}
+
+// WithCacheStore sets the store sharing the verified tokens of
+// a Cache with other caches. The secret keys the stored digests,
+// it has to be the same for all caches sharing the store. Without
+// a secret the store is not used and NewCache reports this as a
+// store failure.
+func WithCacheStore(store CacheStore, secret []byte) Option {
+	return func(o *options) {
+		o.cacheStore = store
+		o.cacheSecret = secret
+	}
+}
+
+// WithLegacyECDSA lets the verification additionally accept ECDSA
+// signatures in the ASN.1 DER encoding created by earlier releases
+// of this package. Signatures following RFC 7518 are still accepted.
+func WithLegacyECDSA() Option {
+	return func(o *options) {
+		o.legacyECDSA = true
+	}
+}
+
+// WithHTTPClient sets the HTTP client used by a RemoteKeySet.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// verifyKey prepares the key for the verification based on the
+// options.
+func (o *options) verifyKey(key Key) Key {
+	if pk, ok := key.(*ecdsa.PublicKey); ok && o.legacyECDSA {
+		return legacyECDSAPublicKey{pk}
+	}
+	return key
+}
+
+// permits checks if the algorithm is allowed by the options.
+func (o *options) permits(algorithm Algorithm) bool {
+	if len(o.algorithms) == 0 {
+		return algorithm != NONE
+	}
+	return slices.Contains(o.algorithms, algorithm)
+}
+
+// permitsEncryption checks if the key management and content
+// encryption algorithms are allowed by the options.
+func (o *options) permitsEncryption(km KeyManagement, enc ContentEncryption) error {
+	if len(o.keyManagement) > 0 && !slices.Contains(o.keyManagement, km) {
+		return &AlgorithmError{Algorithm: Algorithm(km), Err: ErrAlgorithmNotPermitted}
+	}
+	if len(o.encryption) > 0 && !slices.Contains(o.encryption, enc) {
+		return &AlgorithmError{Algorithm: Algorithm(enc), Err: ErrAlgorithmNotPermitted}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Tideland Go JSON Web Token - Redis Cache Store
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// redisTimeout is the timeout for connecting and for commands
+	// if the context has no deadline.
+	redisTimeout = 5 * time.Second
+
+	// redisIdleConns is the maximum number of idle connections.
+	redisIdleConns = 8
+)
+
+// RedisConfig configures a RedisStore.
+type RedisConfig struct {
+	// Address is the address of the server, e.g. "localhost:6379".
+	Address string
+
+	// Prefix is prepended to the keys of the tokens.
+	Prefix string
+
+	// Password is used for the authentication if not empty.
+	Password string
+}
+
+// RedisStore is a CacheStore using a server speaking the Redis
+// protocol RESP. The tokens are stored as SHA-256 hashes prefixed
+// by the key prefix.
+type RedisStore struct {
+	config RedisConfig
+	idle   chan *redisConn
+}
+
+// NewRedisStore creates a store for the configured server.
+func NewRedisStore(config RedisConfig) *RedisStore {
+	return &RedisStore{
+		config: config,
+		idle:   make(chan *redisConn, redisIdleConns),
+	}
+}
+
+// Load implements the CacheStore interface.
+func (s *RedisStore) Load(ctx context.Context, token string, ttl time.Duration) ([]byte, error) {
+	reply, err := s.do(ctx, "GETEX", s.key(token), "PX", milliseconds(ttl))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	digest, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Redis reply %v", reply)
+	}
+	return digest, nil
+}
+
+// Store implements the CacheStore interface.
+func (s *RedisStore) Store(ctx context.Context, token string, digest []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, "SET", s.key(token), string(digest), "PX", milliseconds(ttl))
+	return err
+}
+
+// Close closes the idle connections.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// key returns the key of the token.
+func (s *RedisStore) key(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return s.config.Prefix + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// do performs a command on an idle or new connection.
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var serverErr redisError
+	if err != nil && !errors.As(err, &serverErr) {
+		// The connection state is unknown.
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one.
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+	dialer := net.Dialer{Timeout: redisTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to Redis: %w", err)
+	}
+	conn := &redisConn{
+		Conn:   netConn,
+		reader: bufio.NewReader(netConn),
+		writer: bufio.NewWriter(netConn),
+	}
+	if s.config.Password != "" {
+		if _, err := conn.do(ctx, "AUTH", s.config.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot authenticate at Redis: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+// Error implements the error interface.
+func (e redisError) Error() string {
+	return "Redis error: " + string(e)
+}
+
+// redisConn is a connection to a Redis server.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// do writes the command and reads the reply. Bulk strings are
+// returned as bytes, simple strings as string, integers as int64,
+// and null replies as nil.
+func (c *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot write the Redis command: %w", err)
+	}
+	return c.readReply()
+}
+
+// readReply reads one reply.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the Redis reply: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid Redis reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, redisError(value)
+	case ':':
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis integer %q", value)
+		}
+		return i, nil
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil || size < -1 {
+			return nil, fmt.Errorf("invalid Redis bulk string size %q", value)
+		}
+		if size == -1 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, fmt.Errorf("cannot read the Redis reply: %w", err)
+		}
+		return data[:size], nil
+	case '_':
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported Redis reply %q", line)
+	}
+}
+
+// milliseconds returns the duration as milliseconds, at least one.
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(max(1, d.Milliseconds()), 10)
+}
@@ -0,0 +1,376 @@
+// Tideland Go JSON Web Token - Redis Cache Store - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestRedisStore verifies storing and loading of digests.
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	server := startRedisServer(t, "")
+	store := jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:"})
+	defer store.Close()
+	digest, err := store.Load(ctx, "a.b.c", time.Minute)
+	verify.NoError(t, err)
+	verify.True(t, digest == nil)
+	err = store.Store(ctx, "a.b.c", []byte("dig\r\nest"), time.Minute)
+	verify.NoError(t, err)
+	digest, err = store.Load(ctx, "a.b.c", time.Minute)
+	verify.NoError(t, err)
+	verify.Equal(t, string(digest), "dig\r\nest")
+	// Keys are prefixed hashes of the tokens.
+	for _, key := range server.keys() {
+		verify.True(t, strings.HasPrefix(key, "jwt:"))
+		verify.False(t, strings.Contains(key, "a.b.c"))
+	}
+	// Expiration.
+	err = store.Store(ctx, "d.e.f", []byte("digest"), 50*time.Millisecond)
+	verify.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	digest, err = store.Load(ctx, "d.e.f", time.Minute)
+	verify.NoError(t, err)
+	verify.True(t, digest == nil)
+	// Concurrent usage.
+	var wg sync.WaitGroup
+	errc := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token := fmt.Sprintf("token-%d", i)
+			if err := store.Store(ctx, token, []byte(token), time.Minute); err != nil {
+				errc <- err
+				return
+			}
+			digest, err := store.Load(ctx, token, time.Minute)
+			if err == nil && string(digest) != token {
+				err = fmt.Errorf("got digest %q, want %q", digest, token)
+			}
+			if err != nil {
+				errc <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		verify.NoError(t, err)
+	}
+}
+
+// TestRedisStoreAuth verifies the authentication at the server.
+func TestRedisStoreAuth(t *testing.T) {
+	ctx := context.Background()
+	server := startRedisServer(t, "secret")
+	store := jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:"})
+	defer store.Close()
+	_, err := store.Load(ctx, "a.b.c", time.Minute)
+	verify.ErrorContains(t, err, "NOAUTH")
+	store = jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:", Password: "wrong"})
+	defer store.Close()
+	_, err = store.Load(ctx, "a.b.c", time.Minute)
+	verify.ErrorContains(t, err, "cannot authenticate")
+	store = jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:", Password: "secret"})
+	defer store.Close()
+	err = store.Store(ctx, "a.b.c", []byte("digest"), time.Minute)
+	verify.NoError(t, err)
+	// Unreachable server.
+	server.close()
+	store = jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:"})
+	_, err = store.Load(ctx, "a.b.c", time.Minute)
+	verify.ErrorContains(t, err, "cannot connect")
+}
+
+// TestRedisCache verifies the sharing of verified tokens between
+// caches.
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := startRedisServer(t, "")
+	store := jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:"})
+	defer store.Close()
+	secret := []byte("shared-secret")
+	cacheA := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10, jwt.WithCacheStore(store, secret))
+	cacheB := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10, jwt.WithCacheStore(store, secret))
+	key := []byte("secret")
+	jwtIn, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	st := jwtIn.String()
+	// Verified by cache A, served by cache B.
+	_, err = cacheA.Verify(st, key)
+	verify.NoError(t, err)
+	_, err = cacheB.Verify(st, []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	jwtOut, err := cacheB.Verify(st, key)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	outKey, err := jwtOut.Key()
+	verify.NoError(t, err)
+	verify.Equal(t, string(outKey.([]byte)), "secret")
+	stats := cacheB.Stats()
+	verify.Equal(t, stats.Hits, uint64(1))
+	verify.Equal(t, stats.Misses, uint64(1))
+	verify.Equal(t, stats.Entries, 1)
+	// The store contains no plain thumbprint of the key.
+	hsJWK, err := jwt.NewJWK(key)
+	verify.NoError(t, err)
+	thumbprint, err := hsJWK.Thumbprint(crypto.SHA256)
+	verify.NoError(t, err)
+	digest, err := store.Load(ctx, st, time.Minute)
+	verify.NoError(t, err)
+	verify.NotNil(t, digest)
+	verify.False(t, bytes.Equal(digest, thumbprint))
+	// Caches with another secret do not trust the store.
+	cacheC := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10, jwt.WithCacheStore(store, []byte("other")))
+	_, err = cacheC.Verify(st, key)
+	verify.NoError(t, err)
+	verify.Equal(t, cacheC.Stats().Misses, uint64(1))
+	// Forged entries are not accepted.
+	forged := st[:strings.LastIndex(st, ".")+1] + "AAAA"
+	verify.NoError(t, store.Store(ctx, forged, thumbprint, time.Minute))
+	verify.NoError(t, store.Store(ctx, forged, digest, time.Minute))
+	_, err = cacheB.Verify(forged, key)
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	// Only decoded tokens are not shared.
+	jwtDec, err := jwt.Decode(st + "x")
+	verify.NoError(t, err)
+	_, err = cacheA.Put(jwtDec)
+	verify.NoError(t, err)
+	jwtOut, err = cacheB.Get(jwtDec.String())
+	verify.NoError(t, err)
+	verify.True(t, jwtOut == nil)
+}
+
+// TestRedisCacheFailure verifies that the cache works locally when
+// the store fails.
+func TestRedisCacheFailure(t *testing.T) {
+	ctx := context.Background()
+	server := startRedisServer(t, "")
+	store := jwt.NewRedisStore(jwt.RedisConfig{Address: server.address(), Prefix: "jwt:"})
+	defer store.Close()
+	observer := &testObserver{}
+	cache := jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10,
+		jwt.WithCacheStore(store, []byte("shared-secret")), jwt.WithCacheObserver(observer))
+	key := []byte("secret")
+	jwtIn, err := jwt.Encode(initClaims(), key, jwt.HS512)
+	verify.NoError(t, err)
+	st := jwtIn.String()
+	server.close()
+	// Loading and storing fail, the token is verified locally.
+	jwtOut, err := cache.Verify(st, key)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	jwtOut, err = cache.RequestVerify(jwt.RequestAdd(httptest.NewRequest(http.MethodGet, "http://localhost/", nil), jwtIn), key)
+	verify.NoError(t, err)
+	verify.Equal(t, jwtOut.String(), st)
+	_, err = cache.Verify(st, []byte("wrong"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	jwtOut, err = cache.Get("a.b.c")
+	verify.NoError(t, err)
+	verify.True(t, jwtOut == nil)
+	stats := cache.Stats()
+	verify.Equal(t, stats.Entries, 1)
+	verify.Equal(t, stats.Hits, uint64(1))
+	verify.Equal(t, stats.StoreErrors, uint64(3))
+	verify.Equal(t, observer.storeErrors.Load(), int64(stats.StoreErrors))
+	// A store without secret is not used.
+	observer = &testObserver{}
+	cache = jwt.NewCache(ctx, time.Minute, time.Minute, time.Minute, 10,
+		jwt.WithCacheStore(store, nil), jwt.WithCacheObserver(observer))
+	_, err = cache.Verify(st, key)
+	verify.NoError(t, err)
+	stats = cache.Stats()
+	verify.Equal(t, stats.StoreErrors, uint64(1))
+	verify.Equal(t, observer.storeErrors.Load(), int64(1))
+}
+
+// redisServer is an in-memory fake of a Redis server.
+type redisServer struct {
+	listener net.Listener
+	password string
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	values   map[string]string
+	expires  map[string]time.Time
+}
+
+// startRedisServer starts the fake server, it is closed at the end
+// of the test.
+func startRedisServer(t *testing.T, password string) *redisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	verify.NoError(t, err)
+	s := &redisServer{
+		listener: listener,
+		password: password,
+		conns:    make(map[net.Conn]struct{}),
+		values:   make(map[string]string),
+		expires:  make(map[string]time.Time),
+	}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *redisServer) address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *redisServer) close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *redisServer) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *redisServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// handle reads the commands of a connection and writes the replies.
+func (s *redisServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(args[0])
+		switch {
+		case command == "AUTH":
+			if len(args) == 2 && args[1] == s.password {
+				authenticated = true
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case !authenticated:
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+		default:
+			io.WriteString(conn, s.execute(command, args[1:]))
+		}
+	}
+}
+
+// execute performs the command and returns the reply.
+func (s *redisServer) execute(command string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ttl := func(args []string) (time.Time, bool) {
+		if len(args) != 2 || strings.ToUpper(args[0]) != "PX" {
+			return time.Time{}, false
+		}
+		ms, err := strconv.Atoi(args[1])
+		if err != nil || ms <= 0 {
+			return time.Time{}, false
+		}
+		return time.Now().Add(time.Duration(ms) * time.Millisecond), true
+	}
+	switch command {
+	case "SET":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		expires, ok := ttl(args[2:])
+		if !ok {
+			return "-ERR syntax error\r\n"
+		}
+		s.values[args[0]] = args[1]
+		s.expires[args[0]] = expires
+		return "+OK\r\n"
+	case "GETEX":
+		if len(args) < 1 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		value, ok := s.values[args[0]]
+		if !ok || time.Now().After(s.expires[args[0]]) {
+			delete(s.values, args[0])
+			return "$-1\r\n"
+		}
+		if expires, ok := ttl(args[1:]); ok {
+			s.expires[args[0]] = expires
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", command)
+	}
+}
+
+// readRedisCommand reads a command sent as array of bulk strings.
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	readLine := func(prefix byte) (int, error) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 3 || line[0] != prefix {
+			return 0, fmt.Errorf("invalid line %q", line)
+		}
+		return strconv.Atoi(strings.TrimSpace(line[1:]))
+	}
+	n, err := readLine('*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readLine('$')
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
@@ -0,0 +1,222 @@
+// Tideland Go JSON Web Token - Remote JSON Web Key Set
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxKeySetSize limits the size of a fetched JWK set.
+	maxKeySetSize = 1 << 20
+
+	// minRefreshDelay is the minimum delay between two background
+	// refreshes.
+	minRefreshDelay = time.Second
+)
+
+// RemoteKeySet loads a JSON Web Key Set from a URL and refreshes it
+// in the background. If a token with an unknown key ID has to be
+// verified the set is fetched again, but not more often than the
+// rate limit allows. It can be used as KeySource for the verification
+// like a KeySet.
+type RemoteKeySet struct {
+	ctx       context.Context
+	url       string
+	client    *http.Client
+	interval  time.Duration
+	rateLimit time.Duration
+	fetchMu   sync.Mutex
+	mu        sync.RWMutex
+	set       *KeySet
+	err       error
+	fetched   time.Time
+	expires   time.Time
+}
+
+// NewRemoteKeySet creates a remote key set for the URL. The interval
+// controls how often the set is refreshed if the server does not
+// tell it via the max-age of the Cache-Control header. The rate limit
+// is the minimum duration between two fetches, also when tokens with
+// unknown key IDs are verified. The background refresh stops when the context
+// is cancelled. The HTTP client can be set with WithHTTPClient.
+func NewRemoteKeySet(ctx context.Context, url string, interval, rateLimit time.Duration, options ...Option) *RemoteKeySet {
+	o := newOptions(options)
+	r := &RemoteKeySet{
+		ctx:       ctx,
+		url:       url,
+		client:    o.httpClient,
+		interval:  interval,
+		rateLimit: rateLimit,
+	}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+	go r.backend()
+	return r
+}
+
+// LookupKeys implements the KeySource interface. In case of an
+// unknown key ID the set is fetched again if the rate limit allows it.
+func (r *RemoteKeySet) LookupKeys(kid string, algorithm Algorithm) ([]*JWK, error) {
+	set, err := r.keySet()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := set.Lookup(kid); !ok && kid != "" {
+		// Unknown key ID, maybe the keys have been rotated.
+		if err := r.refresh(r.mayFetch); err != nil {
+			return nil, err
+		}
+		if set, err = r.keySet(); err != nil {
+			return nil, err
+		}
+	}
+	return set.LookupKeys(kid, algorithm)
+}
+
+// KeySet returns the current key set, fetching it if not yet done.
+func (r *RemoteKeySet) KeySet() (*KeySet, error) {
+	return r.keySet()
+}
+
+// Refresh fetches the key set from the URL regardless of the rate
+// limit. In case of an error the previously fetched set stays in use.
+func (r *RemoteKeySet) Refresh() error {
+	return r.refresh(nil)
+}
+
+// refresh fetches the key set if no check is passed or if the check
+// tells that it is needed. The check is done while holding the fetch
+// lock so that concurrent callers don't fetch twice.
+func (r *RemoteKeySet) refresh(needed func() bool) error {
+	r.fetchMu.Lock()
+	defer r.fetchMu.Unlock()
+	if needed != nil && !needed() {
+		return nil
+	}
+	set, maxAge, err := r.fetch()
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetched = now
+	if err != nil {
+		r.err = err
+		r.expires = now.Add(r.rateLimit)
+		return err
+	}
+	r.set = set
+	r.err = nil
+	r.expires = now.Add(r.interval)
+	if maxAge >= 0 {
+		r.expires = now.Add(max(maxAge, r.rateLimit))
+	}
+	return nil
+}
+
+// keySet returns the current key set or fetches it the first time.
+func (r *RemoteKeySet) keySet() (*KeySet, error) {
+	r.mu.RLock()
+	set := r.set
+	r.mu.RUnlock()
+	if set != nil {
+		return set, nil
+	}
+	if err := r.refresh(r.mayFetch); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.set == nil {
+		return nil, r.err
+	}
+	return r.set, nil
+}
+
+// mayFetch checks if the rate limit allows a new fetch.
+func (r *RemoteKeySet) mayFetch() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return time.Since(r.fetched) >= r.rateLimit
+}
+
+// isExpired checks if the key set has to be refreshed.
+func (r *RemoteKeySet) isExpired() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !time.Now().Before(r.expires)
+}
+
+// fetch retrieves the key set and the max age of the Cache-Control
+// header. The max age is negative if none is set.
+func (r *RemoteKeySet) fetch() (*KeySet, time.Duration, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot fetch the JWK set: %w", err)
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot fetch the JWK set: %w: %w", ErrKeyNotFound, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("cannot fetch the JWK set: %w: status %q", ErrKeyNotFound, resp.Status)
+	}
+	set, err := ReadKeySet(io.LimitReader(resp.Body, maxKeySetSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot fetch the JWK set: %w: %w", ErrKeyNotFound, err)
+	}
+	return set, cacheMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// backend is the goroutine refreshing the key set.
+func (r *RemoteKeySet) backend() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-timer.C:
+			// Error is stored for the lookup.
+			_ = r.refresh(r.isExpired)
+			r.mu.RLock()
+			expires := r.expires
+			r.mu.RUnlock()
+			timer.Reset(max(time.Until(expires), minRefreshDelay))
+		}
+	}
+}
+
+// cacheMaxAge returns the max age of the Cache-Control header. A
+// missing max age leads to a negative duration, no-store and no-cache
+// to zero.
+func cacheMaxAge(cacheControl string) time.Duration {
+	maxAge := time.Duration(-1)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds >= 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return maxAge
+}
@@ -0,0 +1,147 @@
+// Tideland Go JSON Web Token - Remote JSON Web Key Set - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestRemoteKeySetVerify verifies the verification using a remote
+// key set including the key rotation.
+func TestRemoteKeySetVerify(t *testing.T) {
+	rsKey, esKey, hsKey := generateJWKs(t)
+	server := newKeySetServer(t, "", publicJWK(t, rsKey))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := jwt.NewRemoteKeySet(ctx, server.URL, time.Hour, 100*time.Millisecond, jwt.WithHTTPClient(server.Client()))
+	claims := initClaims()
+	// Known key.
+	tokenEnc, err := jwt.Encode(claims, rsKey, rsKey.Algorithm)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), remote)
+	verify.NoError(t, err)
+	// Rotate keys, new key ID leads to a re-fetch.
+	server.setKeys(publicJWK(t, rsKey), publicJWK(t, esKey))
+	time.Sleep(150 * time.Millisecond)
+	tokenEnc, err = jwt.Encode(claims, esKey, esKey.Algorithm)
+	verify.NoError(t, err)
+	fetches := server.fetches()
+	_, err = jwt.VerifyWithKeys(tokenEnc.String(), remote)
+	verify.NoError(t, err)
+	verify.Equal(t, server.fetches(), fetches+1)
+	// Unknown key IDs are rate limited.
+	tokenEnc, err = jwt.Encode(claims, hsKey, hsKey.Algorithm)
+	verify.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = jwt.VerifyWithKeys(tokenEnc.String(), remote)
+		verify.ErrorMatch(t, err, ".*no key with ID 'hs'.*")
+	}
+	verify.Equal(t, server.fetches(), fetches+1)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		_, err = jwt.VerifyWithKeys(tokenEnc.String(), remote)
+		verify.ErrorMatch(t, err, ".*no key with ID 'hs'.*")
+	}
+	verify.Equal(t, server.fetches(), fetches+2)
+}
+
+// TestRemoteKeySetCacheControl verifies the background refresh
+// based on the Cache-Control header.
+func TestRemoteKeySetCacheControl(t *testing.T) {
+	rsKey, esKey, _ := generateJWKs(t)
+	server := newKeySetServer(t, "public, max-age=1", publicJWK(t, rsKey))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := jwt.NewRemoteKeySet(ctx, server.URL, time.Hour, 100*time.Millisecond, jwt.WithHTTPClient(server.Client()))
+	set, err := remote.KeySet()
+	verify.NoError(t, err)
+	verify.Length(t, set.Keys, 1)
+	// Rotate keys and wait for the refresh.
+	server.setKeys(publicJWK(t, esKey))
+	time.Sleep(2500 * time.Millisecond)
+	set, err = remote.KeySet()
+	verify.NoError(t, err)
+	verify.Length(t, set.Keys, 1)
+	_, ok := set.Lookup(esKey.KeyID)
+	verify.True(t, ok)
+	// Stop background refresh by context.
+	cancel()
+	fetches := server.fetches()
+	time.Sleep(1500 * time.Millisecond)
+	verify.Equal(t, server.fetches(), fetches)
+}
+
+// TestRemoteKeySetErrors verifies the handling of a failing server.
+func TestRemoteKeySetErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not here", http.StatusNotFound)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := jwt.NewRemoteKeySet(ctx, server.URL, time.Hour, time.Hour, jwt.WithHTTPClient(server.Client()))
+	_, err := remote.KeySet()
+	verify.ErrorMatch(t, err, ".*cannot fetch the JWK set: key not found: status.*404.*")
+	verify.IsError(t, err, jwt.ErrKeyNotFound)
+	tokenEnc, err := jwt.Encode(initClaims(), []byte("secret"), jwt.HS256)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), remote)
+	verify.ErrorMatch(t, err, ".*cannot fetch the JWK set.*")
+}
+
+// keySetServer serves a JWK set and counts the fetches.
+type keySetServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	set     *jwt.KeySet
+	counter atomic.Int64
+}
+
+// newKeySetServer starts a TLS server for the keys.
+func newKeySetServer(t *testing.T, cacheControl string, jwks ...*jwt.JWK) *keySetServer {
+	s := &keySetServer{
+		set: jwt.NewKeySet(jwks...),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.counter.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		err := json.NewEncoder(w).Encode(s.set)
+		verify.NoError(t, err)
+	}))
+	return s
+}
+
+// setKeys replaces the served keys.
+func (s *keySetServer) setKeys(jwks ...*jwt.JWK) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set = jwt.NewKeySet(jwks...)
+}
+
+// fetches returns the number of fetches.
+func (s *keySetServer) fetches() int64 {
+	return s.counter.Load()
+}
@@ -26,22 +26,22 @@ func RequestDecode(req *http.Request) (*JWT, error) {
 }
 
 // RequestVerify retrieves a possible token from a request.
-// The JWT then will be verified.
-func RequestVerify(req *http.Request, key Key) (*JWT, error) {
-	return decode(req, key)
+// The JWT then will be verified using the key and the options.
+func RequestVerify(req *http.Request, key Key, options ...Option) (*JWT, error) {
+	return decode(req, key, options...)
 }
 
 // decodeFromRequest is the generic decoder with possible
 // caching and verification.
-func decode(req *http.Request, key Key) (*JWT, error) {
+func decode(req *http.Request, key Key, options ...Option) (*JWT, error) {
 	// Retrieve token from header.
 	authorization := req.Header.Get("Authorization")
 	if authorization == "" {
-		return nil, fmt.Errorf("request contains no authorization header")
+		return nil, ErrMissingHeader
 	}
 	fields := strings.Fields(authorization)
 	if len(fields) != 2 || fields[0] != "Bearer" {
-		return nil, fmt.Errorf("invalid authorization header: %q", authorization)
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAuthorization, authorization)
 	}
 	// Decode or verify.
 	var jwt *JWT
@@ -49,7 +49,7 @@ func decode(req *http.Request, key Key) (*JWT, error) {
 	if key == nil {
 		jwt, err = Decode(fields[1])
 	} else {
-		jwt, err = Verify(fields[1], key)
+		jwt, err = Verify(fields[1], key, options...)
 	}
 	if err != nil {
 		return nil, err
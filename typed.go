@@ -0,0 +1,171 @@
+// Tideland Go JSON Web Token - Typed Claims
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NumericDate is a time encoded as seconds since the epoch like
+// the registered time claims.
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate creates a numeric date out of the time
+// truncated to seconds.
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, d.Unix(), 10), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// Fractions of seconds are accepted.
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("numeric date is invalid: %w", err)
+	}
+	if i, err := n.Int64(); err == nil {
+		d.Time = time.Unix(i, 0)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("numeric date is invalid: %w", err)
+	}
+	secs, frac := math.Modf(f)
+	d.Time = time.Unix(int64(secs), int64(frac*1e9))
+	return nil
+}
+
+// Audience contains the values of the "aud" claim. A single
+// audience is marshalled as string.
+type Audience []string
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var aud string
+	if err := json.Unmarshal(b, &aud); err == nil {
+		*a = Audience{aud}
+		return nil
+	}
+	var auds []string
+	if err := json.Unmarshal(b, &auds); err != nil {
+		return fmt.Errorf("audience is invalid: %w", err)
+	}
+	*a = Audience(auds)
+	return nil
+}
+
+// Contains checks if the audience contains the passed one.
+func (a Audience) Contains(aud string) bool {
+	return slices.Contains(a, aud)
+}
+
+// RegisteredClaims contains the registered claims of RFC 7519. It
+// is intended to be embedded into own claims structs used with
+// EncodeTyped, DecodeTyped, and VerifyTyped.
+type RegisteredClaims struct {
+	Issuer     string       `json:"iss,omitempty"`
+	Subject    string       `json:"sub,omitempty"`
+	Audience   Audience     `json:"aud,omitempty"`
+	Expiration *NumericDate `json:"exp,omitempty"`
+	NotBefore  *NumericDate `json:"nbf,omitempty"`
+	IssuedAt   *NumericDate `json:"iat,omitempty"`
+	Identifier string       `json:"jti,omitempty"`
+}
+
+// IsValidAt checks the "exp" and "nbf" claims against the passed
+// time. The leeway accounts for clock skew.
+func (rc *RegisteredClaims) IsValidAt(now time.Time, leeway time.Duration) bool {
+	if rc.Expiration != nil && !now.Before(rc.Expiration.Add(leeway)) {
+		return false
+	}
+	if rc.NotBefore != nil && !now.After(rc.NotBefore.Add(-leeway)) {
+		return false
+	}
+	return true
+}
+
+// EncodeTyped creates a JSON Web Token for the claims struct like
+// Encode. The returned token also provides the claims as Claims.
+func EncodeTyped[T any](claims T, key Key, algorithm Algorithm, options ...Option) (*JWT, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode the claims: %w", err)
+	}
+	var c Claims
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cannot encode the claims: claims must be a JSON object: %w", err)
+	}
+	return Encode(c, key, algorithm, options...)
+}
+
+// DecodeTyped creates a token out of a string without verification
+// like Decode. Additionally the claims are unmarshalled into the
+// claims struct.
+func DecodeTyped[T any](token string) (T, *JWT, error) {
+	var claims T
+	jwt, err := Decode(token)
+	if err != nil {
+		return claims, nil, err
+	}
+	if err = unmarshalTyped(jwt, &claims); err != nil {
+		return claims, nil, fmt.Errorf("cannot decode the claims: %w", err)
+	}
+	return claims, jwt, nil
+}
+
+// VerifyTyped creates a token out of a string and verifies it like
+// Verify. Additionally the claims are unmarshalled into the claims
+// struct.
+func VerifyTyped[T any](token string, key Key, options ...Option) (T, *JWT, error) {
+	var claims T
+	jwt, err := Verify(token, key, options...)
+	if err != nil {
+		return claims, nil, err
+	}
+	if err = unmarshalTyped(jwt, &claims); err != nil {
+		return claims, nil, fmt.Errorf("cannot verify the claims: %w", err)
+	}
+	return claims, jwt, nil
+}
+
+// unmarshalTyped unmarshals the payload of the token into
+// the passed value.
+func unmarshalTyped(jwt *JWT, value interface{}) error {
+	parts := strings.Split(jwt.token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: part of the token contains invalid data: %w", ErrMalformedToken, err)
+	}
+	if err = json.Unmarshal(payload, value); err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Tideland Go JSON Web Token - Typed Claims - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// userClaims are own claims embedding the registered ones.
+type userClaims struct {
+	jwt.RegisteredClaims
+	Name  string   `json:"name"`
+	Admin bool     `json:"admin"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// TestTypedRoundTrip verifies encoding, decoding, and verifying
+// of typed claims.
+func TestTypedRoundTrip(t *testing.T) {
+	now := time.Now()
+	key := []byte("secret")
+	claimsIn := userClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:     "issuer",
+			Subject:    "1234567890",
+			Audience:   jwt.Audience{"api"},
+			Expiration: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:   jwt.NewNumericDate(now),
+		},
+		Name:  "John Doe",
+		Admin: true,
+		Roles: []string{"a", "b"},
+	}
+	token, err := jwt.EncodeTyped(claimsIn, key, jwt.HS512)
+	verify.NoError(t, err)
+	// Dynamic claims are available too.
+	sub, ok := token.Claims().Subject()
+	verify.True(t, ok)
+	verify.Equal(t, sub, "1234567890")
+	aud, ok := token.Claims().Audience()
+	verify.True(t, ok)
+	verify.Length(t, aud, 1)
+	verify.Equal(t, aud[0], "api")
+	exp, ok := token.Claims().Expiration()
+	verify.True(t, ok)
+	verify.Equal(t, exp.Unix(), now.Add(time.Hour).Unix())
+	verify.True(t, token.IsValid(time.Minute))
+	// Typed claims after verification.
+	claimsOut, tokenVer, err := jwt.VerifyTyped[userClaims](token.String(), key)
+	verify.NoError(t, err)
+	verify.Equal(t, tokenVer.String(), token.String())
+	verify.Equal(t, claimsOut.Name, "John Doe")
+	verify.True(t, claimsOut.Admin)
+	verify.Equal(t, strings.Join(claimsOut.Roles, ","), "a,b")
+	verify.Equal(t, claimsOut.Issuer, "issuer")
+	verify.True(t, claimsOut.Audience.Contains("api"))
+	verify.Equal(t, claimsOut.Expiration.Unix(), now.Add(time.Hour).Unix())
+	verify.True(t, claimsOut.NotBefore == nil)
+	verify.True(t, claimsOut.IsValidAt(now, 0))
+	verify.False(t, claimsOut.IsValidAt(now.Add(2*time.Hour), time.Minute))
+	// Typed claims after decoding.
+	claimsOut, _, err = jwt.DecodeTyped[userClaims](token.String())
+	verify.NoError(t, err)
+	verify.Equal(t, claimsOut.Subject, "1234567890")
+	// Pointers work as well as options for the verification.
+	validator := jwt.NewValidator(jwt.ExpectAudience("other"))
+	_, _, err = jwt.VerifyTyped[*userClaims](token.String(), key, jwt.WithValidator(validator))
+	verify.IsError(t, err, jwt.ErrInvalidClaim)
+	pClaims, _, err := jwt.VerifyTyped[*userClaims](token.String(), key)
+	verify.NoError(t, err)
+	verify.Equal(t, pClaims.Name, "John Doe")
+	// Verification errors are passed.
+	_, _, err = jwt.VerifyTyped[userClaims](token.String(), []byte("other"))
+	verify.IsError(t, err, jwt.ErrInvalidSignature)
+	// Claims have to be a JSON object.
+	_, err = jwt.EncodeTyped("foo", key, jwt.HS512)
+	verify.ErrorMatch(t, err, ".*claims must be a JSON object.*")
+}
+
+// TestTypedMarshalling verifies the marshalling of numeric dates
+// and audiences.
+func TestTypedMarshalling(t *testing.T) {
+	var rc jwt.RegisteredClaims
+	err := json.Unmarshal([]byte(`{"aud":"a","exp":1700000000,"nbf":1700000000.5}`), &rc)
+	verify.NoError(t, err)
+	verify.Equal(t, strings.Join(rc.Audience, ","), "a")
+	verify.Equal(t, rc.Expiration.Unix(), int64(1700000000))
+	verify.Equal(t, rc.NotBefore.Nanosecond(), 500000000)
+	err = json.Unmarshal([]byte(`{"aud":["a","b"]}`), &rc)
+	verify.NoError(t, err)
+	verify.Equal(t, strings.Join(rc.Audience, ","), "a,b")
+	b, err := json.Marshal(rc)
+	verify.NoError(t, err)
+	verify.Equal(t, string(b), `{"aud":["a","b"],"exp":1700000000,"nbf":1700000000}`)
+	rc = jwt.RegisteredClaims{Audience: jwt.Audience{"a"}}
+	b, err = json.Marshal(rc)
+	verify.NoError(t, err)
+	verify.Equal(t, string(b), `{"aud":"a"}`)
+	err = json.Unmarshal([]byte(`{"exp":"tomorrow"}`), &rc)
+	verify.ErrorMatch(t, err, ".*numeric date is invalid.*")
+	err = json.Unmarshal([]byte(`{"aud":42}`), &rc)
+	verify.ErrorMatch(t, err, ".*audience is invalid.*")
+}
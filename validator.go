@@ -0,0 +1,181 @@
+// Tideland Go JSON Web Token - Validator
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rule checks the claims of a token. The passed time is the
+// reference for all time based checks.
+type Rule func(claims Claims, now time.Time) error
+
+// ValidationError contains all failures of a validation.
+type ValidationError struct {
+	Failures []error
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		msgs[i] = failure.Error()
+	}
+	return "invalid claims: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the failures for errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error {
+	return e.Failures
+}
+
+// Validator checks claims against a set of rules. All rules are
+// checked, failures are reported together as ValidationError.
+type Validator struct {
+	rules []Rule
+}
+
+// NewValidator creates a validator for the passed rules.
+func NewValidator(rules ...Rule) *Validator {
+	return &Validator{
+		rules: rules,
+	}
+}
+
+// Validate checks the claims against all rules.
+func (v *Validator) Validate(claims Claims) error {
+	return v.ValidateAt(claims, time.Now())
+}
+
+// ValidateAt checks the claims against all rules using the passed
+// time as reference.
+func (v *Validator) ValidateAt(claims Claims, now time.Time) error {
+	var failures []error
+	for _, rule := range v.rules {
+		if err := rule(claims, now); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return &ValidationError{
+			Failures: failures,
+		}
+	}
+	return nil
+}
+
+// ExpectIssuer checks if the "iss" claim is one of the issuers.
+func ExpectIssuer(issuers ...string) Rule {
+	return func(claims Claims, now time.Time) error {
+		iss, ok := claims.Issuer()
+		if !ok {
+			return missingClaim("iss")
+		}
+		if !slices.Contains(issuers, iss) {
+			return invalidClaim("iss", ErrInvalidClaim, "issuer '%s' is not expected", iss)
+		}
+		return nil
+	}
+}
+
+// ExpectAudience checks if the "aud" claim contains one of the
+// audiences.
+func ExpectAudience(audiences ...string) Rule {
+	return func(claims Claims, now time.Time) error {
+		auds, ok := claims.Audience()
+		if !ok {
+			return missingClaim("aud")
+		}
+		for _, aud := range auds {
+			if slices.Contains(audiences, aud) {
+				return nil
+			}
+		}
+		return invalidClaim("aud", ErrInvalidClaim, "audience %q is not expected", auds)
+	}
+}
+
+// ExpectSubject checks if the "sub" claim is one of the subjects.
+func ExpectSubject(subjects ...string) Rule {
+	return func(claims Claims, now time.Time) error {
+		sub, ok := claims.Subject()
+		if !ok {
+			return missingClaim("sub")
+		}
+		if !slices.Contains(subjects, sub) {
+			return invalidClaim("sub", ErrInvalidClaim, "subject '%s' is not expected", sub)
+		}
+		return nil
+	}
+}
+
+// RequireClaims checks if all named claims are present.
+func RequireClaims(names ...string) Rule {
+	return func(claims Claims, now time.Time) error {
+		var missing []string
+		for _, name := range names {
+			if !claims.Contains(name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return invalidClaim(strings.Join(missing, ","), ErrMissingClaim, "required claims %q are missing", missing)
+		}
+		return nil
+	}
+}
+
+// ExpectValidTime checks the "exp" and "nbf" claims. The leeway
+// accounts for clock skew.
+func ExpectValidTime(leeway time.Duration) Rule {
+	return func(claims Claims, now time.Time) error {
+		if exp, ok := claims.Expiration(); ok && !now.Before(exp.Add(leeway)) {
+			return invalidClaim("exp", ErrExpired, "token expired at %v", exp)
+		}
+		if nbf, ok := claims.NotBefore(); ok && !now.After(nbf.Add(-leeway)) {
+			return invalidClaim("nbf", ErrNotYetValid, "token is not valid before %v", nbf)
+		}
+		return nil
+	}
+}
+
+// ExpectMaxAge checks if the token has been issued, based on the
+// "iat" claim, not longer ago than the maximum age. The leeway
+// accounts for clock skew.
+func ExpectMaxAge(maxAge, leeway time.Duration) Rule {
+	return func(claims Claims, now time.Time) error {
+		iat, ok := claims.IssuedAt()
+		if !ok {
+			return missingClaim("iat")
+		}
+		if iat.Add(-leeway).After(now) {
+			return invalidClaim("iat", ErrNotYetValid, "token is issued in the future at %v", iat)
+		}
+		if now.Sub(iat) > maxAge+leeway {
+			return invalidClaim("iat", ErrExpired, "token issued at %v is older than %v", iat, maxAge)
+		}
+		return nil
+	}
+}
+
+// ExpectClaim checks the value of the named claim with the predicate.
+// Numbers of verified tokens are passed as json.Number.
+func ExpectClaim(name string, predicate func(value interface{}) bool) Rule {
+	return func(claims Claims, now time.Time) error {
+		value, ok := claims.Get(name)
+		if !ok {
+			return missingClaim(name)
+		}
+		if !predicate(value) {
+			return invalidClaim(name, ErrInvalidClaim, "claim '%s' is invalid", name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+// Tideland Go JSON Web Token - Validator - Unit Tests
+//
+// Copyright (C) 2016-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/jwt"
+)
+
+// TestValidatorValid verifies the validation of valid claims.
+func TestValidatorValid(t *testing.T) {
+	now := time.Now()
+	claims := initClaims()
+	claims.SetIssuer("issuer")
+	claims.SetAudience("foo", "bar")
+	claims.SetIssuedAt(now.Add(-time.Minute))
+	claims.SetNotBefore(now.Add(-time.Minute))
+	claims.SetExpiration(now.Add(time.Hour))
+	v := jwt.NewValidator(
+		jwt.ExpectIssuer("other", "issuer"),
+		jwt.ExpectAudience("bar"),
+		jwt.ExpectSubject("1234567890"),
+		jwt.RequireClaims("name", "admin"),
+		jwt.ExpectValidTime(time.Second),
+		jwt.ExpectMaxAge(time.Hour, time.Second),
+		jwt.ExpectClaim("admin", func(value interface{}) bool {
+			return value == true
+		}),
+	)
+	err := v.Validate(claims)
+	verify.NoError(t, err)
+	err = jwt.NewValidator().Validate(jwt.NewClaims())
+	verify.NoError(t, err)
+}
+
+// TestValidatorInvalid verifies that all failed rules are reported.
+func TestValidatorInvalid(t *testing.T) {
+	now := time.Now()
+	claims := initClaims()
+	claims.SetIssuer("issuer")
+	claims.SetAudience("foo", "bar")
+	claims.SetIssuedAt(now.Add(-2 * time.Hour))
+	claims.SetExpiration(now.Add(-time.Hour))
+	v := jwt.NewValidator(
+		jwt.ExpectIssuer("other"),
+		jwt.ExpectAudience("baz"),
+		jwt.ExpectSubject("0987654321"),
+		jwt.RequireClaims("name", "email", "role"),
+		jwt.ExpectValidTime(time.Second),
+		jwt.ExpectMaxAge(time.Hour, time.Second),
+		jwt.ExpectClaim("admin", func(value interface{}) bool {
+			return value == false
+		}),
+		jwt.ExpectClaim("role", func(value interface{}) bool {
+			return true
+		}),
+	)
+	err := v.Validate(claims)
+	var verr *jwt.ValidationError
+	verify.AsError(t, err, &verr)
+	verify.Length(t, verr.Failures, 8)
+	verify.ErrorMatch(t, err, ".*issuer 'issuer' is not expected.*")
+	verify.ErrorMatch(t, err, `.*audience \["foo" "bar"\] is not expected.*`)
+	verify.ErrorMatch(t, err, ".*subject '1234567890' is not expected.*")
+	verify.ErrorMatch(t, err, `.*required claims \["email" "role"\] are missing.*`)
+	verify.ErrorMatch(t, err, ".*token expired at.*")
+	verify.ErrorMatch(t, err, ".*is older than 1h0m0s.*")
+	verify.ErrorMatch(t, err, ".*claim 'admin' is invalid.*")
+	verify.ErrorMatch(t, err, ".*claim 'role' is missing.*")
+	// Missing claims.
+	err = v.Validate(jwt.NewClaims())
+	verify.AsError(t, err, &verr)
+	verify.ErrorMatch(t, err, ".*claim 'iss' is missing.*")
+	verify.ErrorMatch(t, err, ".*claim 'aud' is missing.*")
+	verify.ErrorMatch(t, err, ".*claim 'sub' is missing.*")
+	verify.ErrorMatch(t, err, ".*claim 'iat' is missing.*")
+	// Time references.
+	claims = jwt.NewClaims()
+	claims.SetIssuedAt(now)
+	claims.SetNotBefore(now)
+	v = jwt.NewValidator(jwt.ExpectValidTime(0), jwt.ExpectMaxAge(time.Minute, 0))
+	err = v.ValidateAt(claims, now.Add(-time.Hour))
+	verify.AsError(t, err, &verr)
+	verify.Length(t, verr.Failures, 2)
+	verify.ErrorMatch(t, err, ".*token is not valid before.*")
+	verify.ErrorMatch(t, err, ".*token is issued in the future.*")
+}
+
+// TestValidatorVerify verifies the validation as part of the
+// token verification.
+func TestValidatorVerify(t *testing.T) {
+	key := []byte("secret")
+	claims := initClaims()
+	claims.SetIssuer("issuer")
+	tokenEnc, err := jwt.Encode(claims, key, jwt.HS256)
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), key, jwt.WithValidator(jwt.NewValidator(jwt.ExpectIssuer("issuer"))))
+	verify.NoError(t, err)
+	_, err = jwt.Verify(tokenEnc.String(), key, jwt.WithValidator(jwt.NewValidator(jwt.ExpectIssuer("other"))))
+	var verr *jwt.ValidationError
+	verify.AsError(t, err, &verr)
+	verify.ErrorMatch(t, err, ".*cannot verify the claims: invalid claims: issuer 'issuer' is not expected.*")
+}